@@ -1,16 +1,22 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"math/big"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"bitbucket.org/liamstask/goose/lib/goose"
 	"github.com/go-chi/chi"
@@ -46,10 +52,226 @@ type ResponseValues struct {
 	Values []string `json:"values"`
 }
 
-var arks = make(map[string]fpe.Algorithm)
+// The IntegerRequestValues type describes the structure of the body of POST
+// requests to integer arks.
+// The structure is json of this structure:
+// {
+//   "values": [1234567, 42],
+//   "tweaks": ["abcdefgh", "12345678"]
+// }
+type IntegerRequestValues struct {
+	Values []json.Number `json:"values"`
+	Tweaks []string      `json:"tweaks"`
+}
+
+// The IntegerResponseValues type describes the structure of all responses from
+// integer arks.
+// The structure is json of this structure:
+// {
+//   "values": [552314, 198]
+// }
+type IntegerResponseValues struct {
+	Values []json.Number `json:"values"`
+}
+
+// The DomainPolicyReport type describes the structure of the response of the
+// domain policy report.
+// The structure is json of this structure:
+// {
+//   "policy": "rev1",
+//   "minimum": 1000000,
+//   "failing": [{"ark": "locals", "domain_size": 999, "reason": "..."}]
+// }
+type DomainPolicyReport struct {
+	Policy  string                `json:"policy"`
+	Minimum json.Number           `json:"minimum"`
+	Failing []DomainPolicyFailure `json:"failing"`
+}
+
+// The DomainPolicyFailure type describes an ark in a DomainPolicyReport that
+// breaks the policy or could not be loaded at all.
+type DomainPolicyFailure struct {
+	Ark        string      `json:"ark"`
+	DomainSize json.Number `json:"domain_size,omitempty"`
+	Reason     string      `json:"reason"`
+}
+
+// The ArkKey type describes the structure of the responses of the endpoints
+// that create an ark or rotate its key.
+// The structure is json of this structure:
+// {
+//   "ark": "ssn",
+//   "key_source": "derived",
+//   "key_version": 1
+// }
+type ArkKey struct {
+	Ark        string `json:"ark"`
+	KeySource  string `json:"key_source"`
+	KeyVersion int    `json:"key_version"`
+}
+
+// The ArkRequest type describes the structure of the body of requests to
+// create an ark, whose fields are the columns of the arks table.
+// The structure is json of this structure:
+// {
+//   "ark_name": "ssn",
+//   "ark_type": "string",
+//   "algorithm_type": "ff1",
+//   "radix": 10,
+//   "min_message_length": 9,
+//   "max_message_length": 9,
+//   "max_tweak_length": 16,
+//   "format": "DDD-DD-DDDD"
+// }
+type ArkRequest struct {
+	Name             string      `json:"ark_name"`
+	ArkType          string      `json:"ark_type"`
+	AlgorithmType    string      `json:"algorithm_type"`
+	Radix            int         `json:"radix"`
+	MinMessageLength int         `json:"min_message_length"`
+	MaxMessageLength int         `json:"max_message_length"`
+	MaxTweakLength   int         `json:"max_tweak_length"`
+	Alphabet         string      `json:"alphabet"`
+	CaseMode         string      `json:"case_mode"`
+	Format           string      `json:"format"`
+	RangeMin         json.Number `json:"range_min"`
+	RangeMax         json.Number `json:"range_max"`
+	// VersionIndicatorWidth reserves that many leading characters of each
+	// message of a "string" ark for the key version it was encrypted with.
+	VersionIndicatorWidth    int    `json:"version_indicator_width"`
+	VersionIndicatorAlphabet string `json:"version_indicator_alphabet"`
+}
+
+// The Ark type holds an algorithm loaded from the arks table. The algorithm
+// already applies the ark's case mode and format template, so the handlers
+// can return its output as is.
+type Ark struct {
+	Algorithm fpe.Algorithm
+	// IntegerRange is set instead of Algorithm for "integer" arks, whose values
+	// are JSON integers.
+	IntegerRange *fpe.IntegerRange
+	// KeyVersion is the version of the key the ark encrypts with.
+	KeyVersion int
+	// previous holds the ark with each earlier key version that was still in
+	// its overlap window when the ark was loaded.
+	previous map[int]previousArk
+	// loaded is when the ark was loaded from the arks table. It is loaded
+	// again once it is older than arkCacheTTL.
+	loaded time.Time
+}
+
+// The previousArk type holds an ark with an earlier key version, which can be
+// used until it expires at the end of its overlap window.
+type previousArk struct {
+	ark     *Ark
+	expires time.Time
+}
+
+// The upperCaseAlgorithm type wraps the algorithm of an ark with the "upper"
+// case mode so that its output is returned in upper case.
+type upperCaseAlgorithm struct {
+	algorithm fpe.Algorithm
+}
+
+func (upper *upperCaseAlgorithm) Encrypt(plaintext string, tweak []byte) (string, error) {
+	message, err := upper.algorithm.Encrypt(plaintext, tweak)
+	return strings.ToUpper(message), err
+}
+
+func (upper *upperCaseAlgorithm) Decrypt(message string, tweak []byte) (string, error) {
+	plaintext, err := upper.algorithm.Decrypt(message, tweak)
+	return strings.ToUpper(plaintext), err
+}
+
+func (upper *upperCaseAlgorithm) MinimumDomainSize() *big.Int {
+	if sizer, ok := upper.algorithm.(fpe.DomainSizer); ok {
+		return sizer.MinimumDomainSize()
+	}
+	return nil
+}
+
+// The translatingAlgorithm type re-encrypts messages from one algorithm to
+// another, for moving stored values to a new key. Encrypt turns a message of
+// from into the message of to with the same plaintext, which is what the
+// translate endpoint uses. Decrypt is its inverse, turning a message of to
+// back into the message of from, so that the type is an fpe.Algorithm.
+type translatingAlgorithm struct {
+	from fpe.Algorithm
+	to   fpe.Algorithm
+}
+
+func (translating *translatingAlgorithm) Encrypt(plaintext string, tweak []byte) (string, error) {
+	decrypted, err := translating.from.Decrypt(plaintext, tweak)
+	if err != nil {
+		return "", err
+	}
+	return translating.to.Encrypt(decrypted, tweak)
+}
+
+func (translating *translatingAlgorithm) Decrypt(message string, tweak []byte) (string, error) {
+	decrypted, err := translating.to.Decrypt(message, tweak)
+	if err != nil {
+		return "", err
+	}
+	return translating.from.Encrypt(decrypted, tweak)
+}
+
+// The blankSkippingAlgorithm type wraps the algorithm of an ark so that blank
+// values are returned as empty strings instead of being encrypted.
+type blankSkippingAlgorithm struct {
+	algorithm fpe.Algorithm
+}
+
+func (blank *blankSkippingAlgorithm) Encrypt(plaintext string, tweak []byte) (string, error) {
+	if strings.TrimSpace(plaintext) == "" {
+		return "", nil
+	}
+	return blank.algorithm.Encrypt(plaintext, tweak)
+}
+
+func (blank *blankSkippingAlgorithm) Decrypt(message string, tweak []byte) (string, error) {
+	if strings.TrimSpace(message) == "" {
+		return "", nil
+	}
+	return blank.algorithm.Decrypt(message, tweak)
+}
+
+// parallelBatchSize is the number of values from which a POST request is
+// spread over several goroutines. Smaller batches are not worth the overhead.
+const parallelBatchSize = 64
+
+var arks = make(map[string]*Ark)
+var arksMutex sync.RWMutex
 var dbConf goose.DBConf
 var serviceKey string
 
+// keyProvider decrypts the keyfile and wraps the data keys of arks on a
+// wrapped key. The KEY_PROVIDER environment variable chooses "kms" (the
+// default), backed by the AWS KMS key set by KMS_KEY_ID, or "local", backed by
+// the local key file set by LOCAL_KEY_FILE.
+var keyProvider KeyProvider
+
+// domainPolicy is the smallest domain an ark may encrypt within. Arks that
+// break it are not loaded. It is set by the DOMAIN_POLICY environment
+// variable, which is "legacy" (the default), "rev1" or a minimum domain size.
+var domainPolicy = fpe.LegacyDomainPolicy
+
+// keyOverlap is how long an ark can still decrypt and translate with its key
+// version before a rotation. It is set by the KEY_OVERLAP environment
+// variable as a duration, eg "168h", and defaults to 30 days.
+var keyOverlap = 30 * 24 * time.Hour
+
+// arkCacheTTL is how long a loaded ark is used before it is loaded again, so
+// that key rotations and destroyed keys made through other servers reach this
+// one. It is set by the ARK_CACHE_TTL environment variable as a duration, eg
+// "1m", and defaults to 5 minutes.
+var arkCacheTTL = 5 * time.Minute
+
+// batchWorkers is the most goroutines a large POST request is spread over. It
+// is set by the BATCH_WORKERS environment variable, and defaults to 0, which
+// uses one per CPU.
+var batchWorkers int
+
 func getValuesFromURLParam(r *http.Request) ([]string, [][]byte, error) {
 	values := r.URL.Query()["q"]
 	if len(values) == 1 {
@@ -81,16 +303,116 @@ func getValuesFromBody(r *http.Request) (RequestValues, error) {
 	return requestValues, err
 }
 
+func getIntegerValuesFromBody(r *http.Request) ([]string, [][]byte, error) {
+	decoder := json.NewDecoder(r.Body)
+	decoder.UseNumber()
+	var requestValues IntegerRequestValues
+	err := decoder.Decode(&requestValues)
+	defer r.Body.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	values := make([]string, len(requestValues.Values))
+	for i := 0; i < len(requestValues.Values); i++ {
+		values[i] = requestValues.Values[i].String()
+	}
+
+	tweaks := make([][]byte, len(requestValues.Tweaks))
+	for i := 0; i < len(requestValues.Tweaks); i++ {
+		tweaks[i], err = hex.DecodeString(requestValues.Tweaks[i])
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return values, tweaks, nil
+}
+
+// decodeTweaks returns the hex decoded tweaks of a POST request, stopping at
+// the number of values since any further tweaks go unused.
+func decodeTweaks(requestValues RequestValues) ([][]byte, error) {
+	n := len(requestValues.Tweaks)
+	if n > len(requestValues.Values) {
+		n = len(requestValues.Values)
+	}
+	tweaks := make([][]byte, n)
+	for i := 0; i < n; i++ {
+		var err error
+		tweaks[i], err = hex.DecodeString(requestValues.Tweaks[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return tweaks, nil
+}
+
+// writeBatch applies batch, fpe.EncryptBatch or fpe.DecryptBatch, to the
+// values with the algorithm of an ark and writes a response body of type
+// ResponseValues. Batches of at least parallelBatchSize values are spread over
+// batchWorkers goroutines. The batch stops if the client goes away.
+func writeBatch(w http.ResponseWriter, r *http.Request, batch func(context.Context, fpe.Algorithm, []string, [][]byte, int) ([]string, []error, error), algorithm fpe.Algorithm, values []string, tweaks [][]byte) {
+	workers := 1
+	if len(values) >= parallelBatchSize {
+		workers = batchWorkers
+	}
+	messages, errs, err := batch(r.Context(), &blankSkippingAlgorithm{algorithm: algorithm}, values, tweaks, workers)
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	for _, err := range errs {
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(ResponseValues{Values: messages})
+}
+
+// writeIntegers applies cipher, the Encrypt or Decrypt method of an integer
+// ark, to each of the values and writes a response body of type
+// IntegerResponseValues.
+func writeIntegers(w http.ResponseWriter, cipher func(*big.Int, []byte) (*big.Int, error), values []string, tweaks [][]byte) {
+	payload := IntegerResponseValues{Values: []json.Number{}}
+	for i := 0; i < len(values); i++ {
+		value, ok := new(big.Int).SetString(strings.TrimSpace(values[i]), 10)
+		if !ok {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("%q is not an integer", values[i]))
+			return
+		}
+		tweak := []byte{}
+		if i < len(tweaks) {
+			tweak = tweaks[i]
+		}
+		message, err := cipher(value, tweak)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		payload.Values = append(payload.Values, json.Number(message.String()))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(payload)
+}
+
 // GetEncryptHandler handles requests for GET /v1/ark/{arkname}/encrypt
 // Takes a query parameter 'q' that is a comma separated list of values to encrypt
 // and returns a response body of type ResponseValues.
 func GetEncryptHandler(w http.ResponseWriter, r *http.Request) {
-	ark := arks[chi.URLParam(r, "arkName")]
+	ark := getArk(chi.URLParam(r, "arkName"))
 	values, tweaks, err := getValuesFromURLParam(r)
 	if err != nil {
 		writeError(w, http.StatusBadRequest, err)
 		return
 	}
+	if ark.IntegerRange != nil {
+		writeIntegers(w, ark.IntegerRange.Encrypt, values, tweaks)
+		return
+	}
 	payload := ResponseValues{Values: []string{}}
 	for i := 0; i < len(values); i++ {
 		value := values[i]
@@ -100,13 +422,13 @@ func GetEncryptHandler(w http.ResponseWriter, r *http.Request) {
 		}
 		message := ""
 		if strings.TrimSpace(string(value)) != "" {
-			message, err = ark.Encrypt(string(value), tweak)
+			message, err = ark.Algorithm.Encrypt(string(value), tweak)
 		}
 		if err != nil {
 			writeError(w, http.StatusBadRequest, err)
 			return
 		}
-		payload.Values = append(payload.Values, strings.ToUpper(message))
+		payload.Values = append(payload.Values, message)
 	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -118,49 +440,48 @@ func GetEncryptHandler(w http.ResponseWriter, r *http.Request) {
 // Takes a json body of structure RequestValues and returns a body of structure
 // ResponseValues.
 func PostEncryptHandler(w http.ResponseWriter, r *http.Request) {
-	ark := arks[chi.URLParam(r, "arkName")]
+	ark := getArk(chi.URLParam(r, "arkName"))
+	if ark.IntegerRange != nil {
+		values, tweaks, err := getIntegerValuesFromBody(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeIntegers(w, ark.IntegerRange.Encrypt, values, tweaks)
+		return
+	}
 	requestValues, err := getValuesFromBody(r)
 	if err != nil {
 		writeError(w, http.StatusBadRequest, err)
 		return
 	}
-	payload := ResponseValues{Values: []string{}}
-	for i := 0; i < len(requestValues.Values); i++ {
-		value := requestValues.Values[i]
-		tweak := []byte{}
-		if i < len(requestValues.Tweaks) {
-			tweak, err = hex.DecodeString(requestValues.Tweaks[i])
-			if err != nil {
-				writeError(w, http.StatusBadRequest, err)
-				return
-			}
-		}
-		message := ""
-		if strings.TrimSpace(string(value)) != "" {
-			message, err = ark.Encrypt(string(value), tweak)
-		}
-		if err != nil {
-			writeError(w, http.StatusBadRequest, err)
-			return
-		}
-		payload.Values = append(payload.Values, strings.ToUpper(message))
+	tweaks, err := decodeTweaks(requestValues)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(payload)
-	return
+	writeBatch(w, r, fpe.EncryptBatch, ark.Algorithm, requestValues.Values, tweaks)
 }
 
 // GetDecryptHandler handles requests for GET /v1/ark/{arkname}/decrypt
 // Takes a query parameter 'q' that is a comma separated list of values to decrypt
-// and returns a response body of type ResponseValues.
+// and returns a response body of type ResponseValues. The 'version' parameter
+// decrypts with an earlier key version that is still in its overlap window.
 func GetDecryptHandler(w http.ResponseWriter, r *http.Request) {
-	ark := arks[chi.URLParam(r, "arkName")]
+	ark, err := getArk(chi.URLParam(r, "arkName")).versionParam(r, "version")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
 	values, tweaks, err := getValuesFromURLParam(r)
 	if err != nil {
 		writeError(w, http.StatusBadRequest, err)
 		return
 	}
+	if ark.IntegerRange != nil {
+		writeIntegers(w, ark.IntegerRange.Decrypt, values, tweaks)
+		return
+	}
 	payload := ResponseValues{Values: []string{}}
 	for i := 0; i < len(values); i++ {
 		value := values[i]
@@ -170,13 +491,13 @@ func GetDecryptHandler(w http.ResponseWriter, r *http.Request) {
 		}
 		message := ""
 		if strings.TrimSpace(string(value)) != "" {
-			message, err = ark.Decrypt(string(value), tweak)
+			message, err = ark.Algorithm.Decrypt(string(value), tweak)
 		}
 		if err != nil {
 			writeError(w, http.StatusBadRequest, err)
 			return
 		}
-		payload.Values = append(payload.Values, strings.ToUpper(message))
+		payload.Values = append(payload.Values, message)
 	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -184,41 +505,359 @@ func GetDecryptHandler(w http.ResponseWriter, r *http.Request) {
 	return
 }
 
-// PostDecryptHandler handles requests for POST /v1/ark/{arkname}/decrypt
-// Takes a json body of structure RequestValues and returns a body of structure
-// ResponseValues.
-func PostDecryptHandler(w http.ResponseWriter, r *http.Request) {
-	ark := arks[chi.URLParam(r, "arkName")]
-	requestValues, err := getValuesFromBody(r)
+// PostDecryptHandler handles requests for POST /v1/ark/{arkname}/decrypt
+// Takes a json body of structure RequestValues and returns a body of structure
+// ResponseValues. The 'version' parameter decrypts with an earlier key version
+// that is still in its overlap window.
+func PostDecryptHandler(w http.ResponseWriter, r *http.Request) {
+	ark, err := getArk(chi.URLParam(r, "arkName")).versionParam(r, "version")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if ark.IntegerRange != nil {
+		values, tweaks, err := getIntegerValuesFromBody(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeIntegers(w, ark.IntegerRange.Decrypt, values, tweaks)
+		return
+	}
+	requestValues, err := getValuesFromBody(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	tweaks, err := decodeTweaks(requestValues)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeBatch(w, r, fpe.DecryptBatch, ark.Algorithm, requestValues.Values, tweaks)
+}
+
+// DomainPolicyReportHandler lists the arks that break the domain policy, so
+// they can be fixed before the policy refuses to load them. The policy param
+// checks against another policy, eg "rev1", instead of the one in use.
+func DomainPolicyReportHandler(w http.ResponseWriter, r *http.Request) {
+	policy := domainPolicy
+	if name := r.URL.Query().Get("policy"); name != "" {
+		var err error
+		policy, err = fpe.ParseDomainPolicy(name)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	db, err := goose.OpenDBFromDBConf(&dbConf)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`SELECT ` + arkColumns + ` FROM arks ORDER BY ark_name`)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer rows.Close()
+
+	report := DomainPolicyReport{
+		Policy:  policy.String(),
+		Minimum: json.Number(policy.Minimum().String()),
+		Failing: []DomainPolicyFailure{}}
+	for rows.Next() {
+		var row arkRow
+		if err := row.scan(rows); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		failure := DomainPolicyFailure{Ark: row.name}
+		ark, err := newArk(row)
+		if err == nil {
+			if sizer, ok := ark.cipher().(fpe.DomainSizer); ok && sizer.MinimumDomainSize() != nil {
+				failure.DomainSize = json.Number(sizer.MinimumDomainSize().String())
+			}
+			err = policy.Check(ark.cipher())
+		}
+		if err != nil {
+			failure.Reason = err.Error()
+			report.Failing = append(report.Failing, failure)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// TranslateHandler handles requests for POST /v1/ark/{arkName}/translate
+// Takes a json body of structure RequestValues holding values encrypted with
+// the key version in the from param and returns them encrypted with the key
+// version in the to param, the current version by default, in a body of
+// structure ResponseValues. The values are decrypted and encrypted again on
+// the server, so their plaintext never reaches the caller. Integer arks take
+// and return the bodies of integer arks. Arks with a version indicator read
+// the key version from each value, so they do not need the from param.
+func TranslateHandler(w http.ResponseWriter, r *http.Request) {
+	ark := getArk(chi.URLParam(r, "arkName"))
+	_, indicated := ark.Algorithm.(*fpe.VersionIndicator)
+	if r.URL.Query().Get("from") == "" && !indicated {
+		writeError(w, http.StatusBadRequest, errors.New("the from param is required"))
+		return
+	}
+	from, err := ark.versionParam(r, "from")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	to, err := ark.versionParam(r, "to")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if ark.IntegerRange != nil {
+		values, tweaks, err := getIntegerValuesFromBody(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeIntegers(w, func(value *big.Int, tweak []byte) (*big.Int, error) {
+			plaintext, err := from.IntegerRange.Decrypt(value, tweak)
+			if err != nil {
+				return nil, err
+			}
+			return to.IntegerRange.Encrypt(plaintext, tweak)
+		}, values, tweaks)
+		return
+	}
+	requestValues, err := getValuesFromBody(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	tweaks, err := decodeTweaks(requestValues)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeBatch(w, r, fpe.EncryptBatch, &translatingAlgorithm{from: from.Algorithm, to: to.Algorithm},
+		requestValues.Values, tweaks)
+}
+
+// RotateArkKeyHandler handles requests for POST /v1/admin/ark/{arkName}/rotate
+// Moves the ark to the next key version, which it encrypts with from then on,
+// and keeps the current version for decrypting and translating until the end
+// of its overlap window. The overlap param sets the window as a duration, eg
+// "72h", instead of keyOverlap. The key_source param chooses whether the new
+// key is "derived" or "wrapped". By default wrapped arks stay wrapped and
+// other arks, including those on the shared service key, move to a derived
+// key. Returns a body of structure ArkKey. Other servers keep encrypting with
+// the old key until their copy of the ark is older than arkCacheTTL.
+func RotateArkKeyHandler(w http.ResponseWriter, r *http.Request) {
+	overlap := keyOverlap
+	if param := r.URL.Query().Get("overlap"); param != "" {
+		var err error
+		overlap, err = time.ParseDuration(param)
+		if err == nil && overlap < 0 {
+			err = errors.New("the overlap cannot be negative")
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	keySource := strings.ToLower(r.URL.Query().Get("key_source"))
+	switch keySource {
+	case "", "derived", "wrapped":
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Errorf("cannot rotate to key source %q", keySource))
+		return
+	}
+
+	db, err := goose.OpenDBFromDBConf(&dbConf)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	row, err := loadArkRow(db, chi.URLParam(r, "arkName"))
+	if err == sql.ErrNoRows {
+		writeError(w, http.StatusNotFound, errors.New("ARK name not configured"))
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	next, err := nextArkRow(row, keySource)
+	if err == nil {
+		_, err = newArk(next)
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer tx.Rollback()
+	_, err = tx.Exec(`INSERT INTO ark_keys (ark_name, key_version, key_source, wrapped_key, expires_at)
+		VALUES (?, ?, ?, ?, DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? SECOND))`,
+		row.name, row.keyVersion, row.keySource, row.wrappedKey, int64(overlap/time.Second))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	result, err := tx.Exec(`UPDATE arks SET key_source=?, key_version=?, wrapped_key=?
+		WHERE ark_name=? AND key_version=?`,
+		next.keySource, next.keyVersion, next.wrappedKey, row.name, row.keyVersion)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if updated, err := result.RowsAffected(); err != nil || updated != 1 {
+		writeError(w, http.StatusConflict, errors.New("the key of the ark changed during the rotation"))
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	dropArk(row.name)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ArkKey{Ark: row.name, KeySource: next.keySource, KeyVersion: next.keyVersion})
+}
+
+// CreateArkHandler handles requests for POST /v1/admin/ark
+// Takes a json body of structure ArkRequest and creates the ark with a new
+// wrapped data key, returning a body of structure ArkKey. Arks that cannot be
+// constructed or break the domain policy are refused.
+func CreateArkHandler(w http.ResponseWriter, r *http.Request) {
+	decoder := json.NewDecoder(r.Body)
+	decoder.UseNumber()
+	var request ArkRequest
+	err := decoder.Decode(&request)
+	defer r.Body.Close()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if strings.TrimSpace(request.Name) == "" {
+		writeError(w, http.StatusBadRequest, errors.New("ark_name is required"))
+		return
+	}
+
+	db, err := goose.OpenDBFromDBConf(&dbConf)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	_, err = loadArkRow(db, request.Name)
+	if err == nil {
+		writeError(w, http.StatusConflict, fmt.Errorf("ark %s already exists", request.Name))
+		return
+	}
+	if err != sql.ErrNoRows {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	row := request.row()
+	row.wrappedKey, err = newWrappedKey(row.name, row.keyVersion)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	ark, err := newArk(row)
+	if err == nil {
+		err = domainPolicy.Check(ark.cipher())
+	}
+	if err == nil {
+		err = ark.indicateVersions(row)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	_, err = db.Exec(`INSERT INTO arks (`+arkColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		row.name, row.arkType, row.algorithmType, row.radix, row.minMessageLength, row.maxMessageLength,
+		row.maxTweakLength, row.alphabet, row.caseMode, row.format, row.rangeMin, row.rangeMax,
+		row.keySource, row.keyVersion, row.wrappedKey, row.versionIndicatorWidth, row.versionIndicatorAlphabet)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(ArkKey{Ark: row.name, KeySource: row.keySource, KeyVersion: row.keyVersion})
+}
+
+// DestroyArkKeyHandler handles requests for DELETE /v1/admin/ark/{arkName}/key
+// Deletes the wrapped data key of an ark and its earlier key versions, so
+// that nothing it encrypted can be decrypted again, and the ark no longer
+// loads. Other servers keep the ark until their copy is older than
+// arkCacheTTL.
+func DestroyArkKeyHandler(w http.ResponseWriter, r *http.Request) {
+	db, err := goose.OpenDBFromDBConf(&dbConf)
 	if err != nil {
-		writeError(w, http.StatusBadRequest, err)
+		writeError(w, http.StatusInternalServerError, err)
 		return
 	}
-	payload := ResponseValues{Values: []string{}}
-	for i := 0; i < len(requestValues.Values); i++ {
-		value := requestValues.Values[i]
-		tweak := []byte{}
-		if i < len(requestValues.Tweaks) {
-			tweak, err = hex.DecodeString(requestValues.Tweaks[i])
-			if err != nil {
-				writeError(w, http.StatusBadRequest, err)
-				return
-			}
-		}
-		message := ""
-		if strings.TrimSpace(string(value)) != "" {
-			message, err = ark.Decrypt(string(value), tweak)
-		}
-		if err != nil {
-			writeError(w, http.StatusBadRequest, err)
-			return
-		}
-		payload.Values = append(payload.Values, strings.ToUpper(message))
+	defer db.Close()
+
+	row, err := loadArkRow(db, chi.URLParam(r, "arkName"))
+	if err == sql.ErrNoRows {
+		writeError(w, http.StatusNotFound, errors.New("ARK name not configured"))
+		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(payload)
-	return
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if strings.ToLower(row.keySource) != "wrapped" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("ark %s has no wrapped key", row.name))
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer tx.Rollback()
+	_, err = tx.Exec(`UPDATE arks SET wrapped_key=NULL WHERE ark_name=?`, row.name)
+	if err == nil {
+		_, err = tx.Exec(`DELETE FROM ark_keys WHERE ark_name=?`, row.name)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	dropArk(row.name)
+
+	w.WriteHeader(http.StatusNoContent)
 }
 
 // Health is just an endpoint that returns an empty response
@@ -246,6 +885,18 @@ func ArkCtx(next http.Handler) http.Handler {
 // APIKeyValid checks to make sure the provided API key is valid and returns an error
 // otherwise.
 func APIKeyValid(next http.Handler) http.Handler {
+	return apiKeyValid(next, "SELECT value FROM api_keys WHERE value=?")
+}
+
+// AdminKeyValid checks to make sure the provided API key is valid and marked
+// as an admin key, and returns an error otherwise.
+func AdminKeyValid(next http.Handler) http.Handler {
+	return apiKeyValid(next, "SELECT value FROM api_keys WHERE value=? AND is_admin")
+}
+
+// apiKeyValid checks the provided API key with query, which must select a row
+// for valid keys, and returns an error if it selects none.
+func apiKeyValid(next http.Handler, query string) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		key := strings.Trim(r.Header.Get("Authorization"), "Bearer ")
 		if key == "" {
@@ -262,7 +913,7 @@ func APIKeyValid(next http.Handler) http.Handler {
 		defer db.Close()
 
 		var foundKey string // foundKey doesn't do anything atm, Scan requires an arg
-		err = db.QueryRow("SELECT value FROM api_keys WHERE value=?", key).Scan(&foundKey)
+		err = db.QueryRow(query, key).Scan(&foundKey)
 		switch {
 		case err == sql.ErrNoRows:
 			w.WriteHeader(http.StatusForbidden)
@@ -281,87 +932,741 @@ func writeError(w http.ResponseWriter, status int, err error) {
 	w.Write([]byte(err.Error()))
 }
 
+// getArk returns the ark loaded for arkName, or nil if it has not been loaded
+// yet. It is safe to call from concurrent handlers.
+func getArk(arkName string) *Ark {
+	arksMutex.RLock()
+	defer arksMutex.RUnlock()
+	return arks[arkName]
+}
+
 // check arks to see if arkName already in memory, if not check db
 // every db check will populate ark[arkName] if found in db.
 // if not found in db, return false
+// An ark older than arkCacheTTL is loaded again. If the db cannot be reached
+// the old ark is kept, but one that no longer loads is dropped.
 func findAlgorithm(arkName string) bool {
-	_, found := arks[arkName]
-	if found {
+	cached := getArk(arkName)
+	if cached != nil && time.Since(cached.loaded) < arkCacheTTL {
 		return true
 	}
 
 	db, err := goose.OpenDBFromDBConf(&dbConf)
 	if err != nil {
-	} // handle this error
+		log.Println(err)
+		return cached != nil
+	}
 	defer db.Close()
 
-	var (
-		name             string
-		algorithmType    string
-		radix            int
-		minMessageLength int
-		maxMessageLength int
-		maxTweakLength   int
-	)
-
-	err = db.QueryRow("SELECT * FROM arks WHERE ark_name=?", arkName).Scan(
-		&name, &algorithmType, &radix, &minMessageLength, &maxMessageLength,
-		&maxTweakLength)
+	row, err := loadArkRow(db, arkName)
+	if err != nil && err != sql.ErrNoRows && cached != nil {
+		log.Println(err)
+		return true
+	}
 	if err != nil {
 		fmt.Println(err)
+		dropArk(arkName)
 		return false
 	}
 
-	if strings.ToLower(algorithmType) == "ff1" {
-		newAlgorithm, _ := fpe.NewFF1(serviceKey, radix, minMessageLength, maxMessageLength, maxTweakLength)
-		arks[name] = &newAlgorithm
-	} else if strings.ToLower(algorithmType) == "ff3" {
-		newAlgorithm, _ := fpe.NewFF3(serviceKey, radix, minMessageLength, maxMessageLength)
-		arks[name] = &newAlgorithm
+	ark, err := newArk(row)
+	if err == nil {
+		err = domainPolicy.Check(ark.cipher())
+	}
+	if err == nil {
+		ark.previous, err = loadPreviousArks(db, row)
+	}
+	if err == nil {
+		err = ark.indicateVersions(row)
+	}
+	if err != nil {
+		log.Printf("could not load ark %s: %v\n", row.name, err)
+		dropArk(row.name)
+		return false
 	}
 
+	ark.loaded = time.Now()
+	arksMutex.Lock()
+	defer arksMutex.Unlock()
+	arks[row.name] = ark
+
 	return true
 }
 
+// dropArk removes the loaded ark for arkName, so that it is loaded again
+// before it is next used.
+func dropArk(arkName string) {
+	arksMutex.Lock()
+	defer arksMutex.Unlock()
+	delete(arks, arkName)
+}
+
+// arkColumns are the columns of the arks table that are read into an arkRow,
+// in the order arkRow.scan reads them.
+const arkColumns = `ark_name, ark_type, algorithm_type, radix, min_message_length,
+	max_message_length, max_tweak_length, alphabet, case_mode, format,
+	range_min, range_max, key_source, key_version, wrapped_key,
+	version_indicator_width, version_indicator_alphabet`
+
+// The arkRow type holds the columns of a row of the arks table.
+type arkRow struct {
+	name             string
+	arkType          string
+	algorithmType    string
+	radix            int
+	minMessageLength int
+	maxMessageLength int
+	maxTweakLength   sql.NullInt64
+	alphabet         sql.NullString
+	caseMode         string
+	format           sql.NullString
+	rangeMin         sql.NullString
+	rangeMax         sql.NullString
+	keySource        string
+	keyVersion       int
+	wrappedKey       []byte
+	// versionIndicatorWidth is the number of leading characters of each
+	// message that hold its key version, or 0 for none.
+	versionIndicatorWidth    int
+	versionIndicatorAlphabet sql.NullString
+}
+
+// scan reads the arkColumns of a row into row.
+func (row *arkRow) scan(scanner interface {
+	Scan(dest ...interface{}) error
+}) error {
+	return scanner.Scan(&row.name, &row.arkType, &row.algorithmType, &row.radix,
+		&row.minMessageLength, &row.maxMessageLength, &row.maxTweakLength, &row.alphabet,
+		&row.caseMode, &row.format, &row.rangeMin, &row.rangeMax, &row.keySource, &row.keyVersion,
+		&row.wrappedKey, &row.versionIndicatorWidth, &row.versionIndicatorAlphabet)
+}
+
+// row returns the row of the arks table for a new ark on a wrapped key,
+// filling in the defaults of the table.
+func (request *ArkRequest) row() arkRow {
+	row := arkRow{
+		name:             request.Name,
+		arkType:          request.ArkType,
+		algorithmType:    request.AlgorithmType,
+		radix:            request.Radix,
+		minMessageLength: request.MinMessageLength,
+		maxMessageLength: request.MaxMessageLength,
+		maxTweakLength:   sql.NullInt64{Int64: int64(request.MaxTweakLength), Valid: true},
+		alphabet:         sql.NullString{String: request.Alphabet, Valid: request.Alphabet != ""},
+		caseMode:         request.CaseMode,
+		format:           sql.NullString{String: request.Format, Valid: request.Format != ""},
+		rangeMin:         sql.NullString{String: request.RangeMin.String(), Valid: request.RangeMin != ""},
+		rangeMax:         sql.NullString{String: request.RangeMax.String(), Valid: request.RangeMax != ""},
+		keySource:        "wrapped",
+		keyVersion:       1,
+	}
+	row.versionIndicatorWidth = request.VersionIndicatorWidth
+	row.versionIndicatorAlphabet = sql.NullString{String: request.VersionIndicatorAlphabet,
+		Valid: request.VersionIndicatorAlphabet != ""}
+	if row.arkType == "" {
+		row.arkType = "string"
+	}
+	if row.caseMode == "" {
+		row.caseMode = "upper"
+	}
+	return row
+}
+
+// newArk constructs the ark described by a row of the arks table, with the
+// key chosen by its key source and version. The radix, alphabet and case mode
+// of a row are only used by "string" arks, and its message lengths by "string"
+// and "card" arks. The range is only used by "integer" arks, which use no
+// format.
+func newArk(row arkRow) (*Ark, error) {
+	key, err := arkKey(row)
+	if err != nil {
+		return nil, err
+	}
+	maxTweakLength := int(row.maxTweakLength.Int64)
+	if row.versionIndicatorWidth != 0 &&
+		(strings.ToLower(row.arkType) != "string" || row.format.String != "") {
+		return nil, errors.New("only string arks without a format can have a version indicator")
+	}
+	if strings.ToLower(row.arkType) == "integer" {
+		integerRange, err := newIntegerRange(key, row.algorithmType, row.rangeMin.String, row.rangeMax.String,
+			maxTweakLength)
+		if err != nil {
+			return nil, err
+		}
+		return &Ark{IntegerRange: integerRange, KeyVersion: row.keyVersion}, nil
+	}
+
+	var algorithm fpe.Algorithm
+	switch strings.ToLower(row.arkType) {
+	case "string":
+		// The version indicator takes its width out of the message, so that the
+		// whole message still fits the maximum length of the ark.
+		maxMessageLength := row.maxMessageLength - row.versionIndicatorWidth
+		if maxMessageLength < row.minMessageLength {
+			return nil, errors.New("the version indicator leaves no room for messages of the minimum length")
+		}
+		algorithm, err = newStringAlgorithm(key, row.algorithmType, row.alphabet.String, row.caseMode, row.radix,
+			row.minMessageLength, maxMessageLength, maxTweakLength)
+	case "card":
+		algorithm, err = newCardAlgorithm(key, row.algorithmType, row.minMessageLength, row.maxMessageLength,
+			maxTweakLength)
+	case "regex":
+		algorithm, err = newRegexAlgorithm(key, row.algorithmType, row.format.String, maxTweakLength)
+	case "email":
+		algorithm, err = newEmailAlgorithm(key, row.algorithmType, row.format.String, maxTweakLength)
+	case "date":
+		algorithm, err = newDateAlgorithm(key, row.algorithmType, row.format.String, maxTweakLength)
+	case "mixed":
+		algorithm, err = newMixedRadixAlgorithm(key, row.algorithmType, row.format.String, maxTweakLength)
+	default:
+		err = fmt.Errorf("unknown ark type %q", row.arkType)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if row.format.String != "" && usesTemplate(row.arkType) {
+		template, err := fpe.NewTemplate(algorithm, row.format.String)
+		if err != nil {
+			return nil, err
+		}
+		algorithm = &template
+	}
+
+	return &Ark{Algorithm: algorithm, KeyVersion: row.keyVersion}, nil
+}
+
+// loadArkRow reads the row of the arks table for arkName.
+func loadArkRow(db *sql.DB, arkName string) (arkRow, error) {
+	var row arkRow
+	err := row.scan(db.QueryRow(`SELECT `+arkColumns+` FROM arks WHERE ark_name=?`, arkName))
+	return row, err
+}
+
+// arkKey returns the key of an ark, in hexadecimal. The "shared" key source
+// is the service key itself, which every ark used before keys were derived,
+// and "derived" is a key derived from the service key for the ark name and
+// key version alone, so that learning the key of one ark reveals nothing of
+// the others. The "wrapped" key source is a random data key generated for the
+// ark, stored wrapped by the key provider, which can be rotated or destroyed
+// without touching the service key.
+func arkKey(row arkRow) (string, error) {
+	switch strings.ToLower(row.keySource) {
+	case "shared":
+		return serviceKey, nil
+	case "derived":
+		return fpe.DeriveKey(serviceKey, arkKeyInfo(row.name, row.keyVersion))
+	case "wrapped":
+		if row.wrappedKey == nil {
+			return "", errors.New("the key of the ark was destroyed")
+		}
+		key, err := keyProvider.UnwrapKey(row.wrappedKey, string(arkKeyInfo(row.name, row.keyVersion)))
+		if err != nil {
+			return "", err
+		}
+		defer fpe.ZeroBytes(key)
+		return hex.EncodeToString(key), nil
+	}
+	return "", fmt.Errorf("unknown key source %q", row.keySource)
+}
+
+// arkKeyInfo returns the HKDF info of the derived key of an ark, which is
+// also the label of its wrapped key. The version comes first and ends at the
+// space, so no two arks share an info.
+func arkKeyInfo(arkName string, keyVersion int) []byte {
+	return []byte(fmt.Sprintf("fpe ark key v%d %s", keyVersion, arkName))
+}
+
+// nextArkRow returns the row of an ark as it will be once its key is rotated
+// to the next version on keySource, "derived" or "wrapped", generating a new
+// data key for a wrapped key. An empty keySource keeps wrapped arks wrapped
+// and moves other arks to a derived key.
+func nextArkRow(row arkRow, keySource string) (arkRow, error) {
+	if keySource == "" {
+		keySource = "derived"
+		if strings.ToLower(row.keySource) == "wrapped" {
+			keySource = "wrapped"
+		}
+	}
+	row.keySource = keySource
+	row.keyVersion++
+	row.wrappedKey = nil
+	if keySource == "wrapped" {
+		wrapped, err := newWrappedKey(row.name, row.keyVersion)
+		if err != nil {
+			return row, err
+		}
+		row.wrappedKey = wrapped
+	}
+	return row, nil
+}
+
+// loadPreviousArks constructs the ark of a row with each of its earlier key
+// versions whose overlap window has not ended.
+func loadPreviousArks(db *sql.DB, row arkRow) (map[int]previousArk, error) {
+	rows, err := db.Query(`SELECT key_version, key_source, wrapped_key,
+		TIMESTAMPDIFF(SECOND, UTC_TIMESTAMP(), expires_at) FROM ark_keys
+		WHERE ark_name=? AND expires_at > UTC_TIMESTAMP()`, row.name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	previous := make(map[int]previousArk)
+	for rows.Next() {
+		versionRow := row
+		var remaining int64
+		err := rows.Scan(&versionRow.keyVersion, &versionRow.keySource, &versionRow.wrappedKey, &remaining)
+		if err != nil {
+			return nil, err
+		}
+		ark, err := newArk(versionRow)
+		if err != nil {
+			return nil, err
+		}
+		previous[versionRow.keyVersion] = previousArk{ark: ark, expires: now.Add(time.Duration(remaining) * time.Second)}
+	}
+	return previous, rows.Err()
+}
+
+// newWrappedKey generates a data key for version keyVersion of an ark and
+// returns it wrapped by the key provider.
+func newWrappedKey(arkName string, keyVersion int) ([]byte, error) {
+	key, err := newDataKey()
+	if err != nil {
+		return nil, err
+	}
+	defer fpe.ZeroBytes(key)
+	return keyProvider.WrapKey(key, string(arkKeyInfo(arkName, keyVersion)))
+}
+
+// version returns the ark with key version keyVersion, which is either its
+// current version or an earlier one whose overlap window has not ended.
+func (ark *Ark) version(keyVersion int) (*Ark, error) {
+	if keyVersion == ark.KeyVersion {
+		return ark, nil
+	}
+	previous, ok := ark.previous[keyVersion]
+	if !ok || !time.Now().Before(previous.expires) {
+		return nil, fmt.Errorf("key version %d of the ark is not available", keyVersion)
+	}
+	return previous.ark, nil
+}
+
+// versionParam returns the ark with the key version in a param of a request,
+// or the ark itself if the param is not set.
+func (ark *Ark) versionParam(r *http.Request, param string) (*Ark, error) {
+	value := r.URL.Query().Get(param)
+	if value == "" {
+		return ark, nil
+	}
+	keyVersion, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, fmt.Errorf("the %s param must be a key version", param)
+	}
+	return ark.version(keyVersion)
+}
+
+// indicateVersions wraps the algorithm of the ark and of each of its earlier
+// key versions so that each message starts with the key version it was
+// encrypted with, if the row sets a version indicator. Decrypting with any of
+// them reads the key version from the message, so a stored value decrypts
+// whichever version encrypted it. The indicator is written in its own
+// alphabet, which defaults to the decimal digits.
+func (ark *Ark) indicateVersions(row arkRow) error {
+	if row.versionIndicatorWidth == 0 {
+		return nil
+	}
+	alphabetString := row.versionIndicatorAlphabet.String
+	if alphabetString == "" {
+		alphabetString = "0123456789"
+	}
+	alphabet, err := fpe.NewAlphabet(alphabetString)
+	if err != nil {
+		return err
+	}
+
+	versions := []*Ark{ark}
+	algorithms := map[int]fpe.Algorithm{ark.KeyVersion: ark.Algorithm}
+	for keyVersion, previous := range ark.previous {
+		versions = append(versions, previous.ark)
+		algorithms[keyVersion] = previous.ark.Algorithm
+	}
+	lookup := func(keyVersion int) (fpe.Algorithm, error) {
+		if _, err := ark.version(keyVersion); err != nil {
+			return nil, err
+		}
+		return algorithms[keyVersion], nil
+	}
+
+	for _, version := range versions {
+		indicator, err := fpe.NewVersionIndicator(alphabet, row.versionIndicatorWidth, version.KeyVersion,
+			ark.KeyVersion, lookup)
+		if err != nil {
+			return err
+		}
+		version.Algorithm = &indicator
+	}
+	return nil
+}
+
+// cipher returns whichever of the Algorithm and IntegerRange of the ark is
+// set.
+func (ark *Ark) cipher() interface{} {
+	if ark.IntegerRange != nil {
+		return ark.IntegerRange
+	}
+	return ark.Algorithm
+}
+
+// usesTemplate returns whether the format of an ark type is a format template.
+// Other ark types use their format for their own settings.
+func usesTemplate(arkType string) bool {
+	switch strings.ToLower(arkType) {
+	case "string", "card":
+		return true
+	}
+	return false
+}
+
+// newStringAlgorithm constructs the algorithm of a "string" ark, which
+// encrypts messages written in the ark's alphabet and returns them in the
+// ark's case mode.
+func newStringAlgorithm(key, algorithmType, alphabetString, caseMode string, radix, minMessageLength, maxMessageLength, maxTweakLength int) (fpe.Algorithm, error) {
+	algorithm, err := newAlgorithm(key, algorithmType, alphabetString, radix, minMessageLength,
+		maxMessageLength, maxTweakLength)
+	if err != nil {
+		return nil, err
+	}
+	upperCase, err := useUpperCase(caseMode, alphabetString, radix)
+	if err != nil {
+		return nil, err
+	}
+	if upperCase {
+		return &upperCaseAlgorithm{algorithm: algorithm}, nil
+	}
+	return algorithm, nil
+}
+
+// newCardAlgorithm constructs the algorithm of a "card" ark.
+func newCardAlgorithm(key, algorithmType string, minMessageLength, maxMessageLength, maxTweakLength int) (fpe.Algorithm, error) {
+	if strings.ToLower(algorithmType) != "ff1" {
+		return nil, fmt.Errorf("card arks need the ff1 algorithm type, not %q", algorithmType)
+	}
+	card, err := fpe.NewCardNumberWithLengths(key, minMessageLength, maxMessageLength, maxTweakLength)
+	return &card, err
+}
+
+// newRegexAlgorithm constructs the algorithm of a "regex" ark.
+func newRegexAlgorithm(key, algorithmType, pattern string, maxTweakLength int) (fpe.Algorithm, error) {
+	if strings.ToLower(algorithmType) != "ff1" {
+		return nil, fmt.Errorf("regex arks need the ff1 algorithm type, not %q", algorithmType)
+	}
+	regexFormat, err := fpe.NewRegexFormat(key, pattern, maxTweakLength)
+	return &regexFormat, err
+}
+
+// newEmailAlgorithm constructs the algorithm of an "email" ark.
+func newEmailAlgorithm(key, algorithmType, format string, maxTweakLength int) (fpe.Algorithm, error) {
+	if strings.ToLower(algorithmType) != "ff1" {
+		return nil, fmt.Errorf("email arks need the ff1 algorithm type, not %q", algorithmType)
+	}
+	var mode fpe.EmailDomainMode
+	switch strings.ToLower(format) {
+	case "", "keep-domain":
+		mode = fpe.KeepEmailDomain
+	case "encrypt-domain":
+		mode = fpe.EncryptEmailDomain
+	default:
+		return nil, fmt.Errorf("unknown email format %q", format)
+	}
+	email, err := fpe.NewEmail(key, mode, maxTweakLength)
+	return &email, err
+}
+
+// newMixedRadixAlgorithm constructs the algorithm of a "mixed" ark.
+func newMixedRadixAlgorithm(key, algorithmType, pattern string, maxTweakLength int) (fpe.Algorithm, error) {
+	if strings.ToLower(algorithmType) != "ff1" {
+		return nil, fmt.Errorf("mixed arks need the ff1 algorithm type, not %q", algorithmType)
+	}
+	mixedRadix, err := fpe.NewMixedRadixFromPattern(key, pattern, maxTweakLength)
+	return &mixedRadix, err
+}
+
+// newDateAlgorithm constructs the algorithm of a "date" ark.
+func newDateAlgorithm(key, algorithmType, format string, maxTweakLength int) (fpe.Algorithm, error) {
+	if strings.ToLower(algorithmType) != "ff1" {
+		return nil, fmt.Errorf("date arks need the ff1 algorithm type, not %q", algorithmType)
+	}
+
+	minimum := time.Date(1900, time.January, 1, 0, 0, 0, 0, time.UTC)
+	maximum := time.Date(2099, time.December, 31, 0, 0, 0, 0, time.UTC)
+	mode := fpe.EncryptWholeDate
+	var layouts []string
+	for _, setting := range strings.Split(format, ";") {
+		if strings.TrimSpace(setting) == "" {
+			continue
+		}
+		parts := strings.SplitN(setting, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("date setting %q is not of the form key=value", setting)
+		}
+		key, value := strings.ToLower(strings.TrimSpace(parts[0])), strings.TrimSpace(parts[1])
+		var err error
+		switch key {
+		case "from":
+			minimum, err = time.Parse("2006-01-02", value)
+		case "to":
+			maximum, err = time.Parse("2006-01-02", value)
+		case "keep":
+			switch strings.ToLower(value) {
+			case "none":
+				mode = fpe.EncryptWholeDate
+			case "year":
+				mode = fpe.KeepDateYear
+			case "year-month":
+				mode = fpe.KeepDateYearMonth
+			default:
+				err = fmt.Errorf("unknown date keep setting %q", value)
+			}
+		case "layouts":
+			layouts = strings.Split(value, "|")
+		default:
+			err = fmt.Errorf("unknown date setting %q", key)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	date, err := fpe.NewDate(key, minimum, maximum, mode, layouts, maxTweakLength)
+	return &date, err
+}
+
+// newIntegerRange constructs the integer range of an "integer" ark.
+func newIntegerRange(key, algorithmType, rangeMin, rangeMax string, maxTweakLength int) (*fpe.IntegerRange, error) {
+	if strings.ToLower(algorithmType) != "ff1" {
+		return nil, fmt.Errorf("integer arks need the ff1 algorithm type, not %q", algorithmType)
+	}
+	minimum, ok := new(big.Int).SetString(rangeMin, 10)
+	if !ok {
+		return nil, fmt.Errorf("range_min %q is not an integer", rangeMin)
+	}
+	maximum, ok := new(big.Int).SetString(rangeMax, 10)
+	if !ok {
+		return nil, fmt.Errorf("range_max %q is not an integer", rangeMax)
+	}
+	integerRange, err := fpe.NewIntegerRange(key, minimum, maximum, maxTweakLength)
+	return &integerRange, err
+}
+
+// useUpperCase checks that an ark's case mode suits its alphabet and returns
+// whether its output should be upper cased.
+// The "upper" mode is the original behaviour of returning output in upper
+// case, while "preserve" returns output in the natural case of the alphabet.
+// Both accept input in either case and need a case insensitive alphabet, which
+// the default alphabet is up to radix 36. The "sensitive" mode treats case as
+// part of the message, as the default alphabet does from radix 37 to 62.
+// Arks with their own alphabet always return output as the alphabet writes it.
+func useUpperCase(caseMode, alphabetString string, radix int) (bool, error) {
+	switch strings.ToLower(caseMode) {
+	case "upper", "preserve":
+		if alphabetString == "" && radix > 36 {
+			return false, fmt.Errorf("radix %d is case sensitive, so the case mode must be sensitive", radix)
+		}
+		return alphabetString == "" && strings.ToLower(caseMode) == "upper", nil
+	case "sensitive":
+		if alphabetString == "" && radix <= 36 {
+			return false, fmt.Errorf("radix %d ignores case, so the case mode cannot be sensitive", radix)
+		}
+		return false, nil
+	}
+	return false, fmt.Errorf("unknown case mode %q", caseMode)
+}
+
+// newAlgorithm constructs the algorithm described by a row of the arks table.
+// An empty alphabetString selects the default 0-9a-z alphabet.
+func newAlgorithm(key, algorithmType, alphabetString string, radix, minMessageLength, maxMessageLength, maxTweakLength int) (fpe.Algorithm, error) {
+	if alphabetString == "" {
+		switch strings.ToLower(algorithmType) {
+		case "ff1":
+			algorithm, err := fpe.NewFF1(key, radix, minMessageLength, maxMessageLength, maxTweakLength)
+			return &algorithm, err
+		case "ff3":
+			algorithm, err := fpe.NewFF3(key, radix, minMessageLength, maxMessageLength)
+			return &algorithm, err
+		case "ff3-1":
+			algorithm, err := fpe.NewFF31(key, radix, minMessageLength, maxMessageLength)
+			return &algorithm, err
+		case "small-domain":
+			algorithm, warning, err := fpe.NewSmallDomain(key, radix, minMessageLength, maxMessageLength, maxTweakLength)
+			logWarning(warning, err)
+			return &algorithm, err
+		}
+		return nil, fmt.Errorf("unknown algorithm type %q", algorithmType)
+	}
+
+	alphabet, err := fpe.NewAlphabet(alphabetString)
+	if err != nil {
+		return nil, err
+	}
+	if alphabet.Radix() != radix {
+		return nil, fmt.Errorf("radix %d does not match the %d characters of the alphabet", radix, alphabet.Radix())
+	}
+	switch strings.ToLower(algorithmType) {
+	case "ff1":
+		algorithm, err := fpe.NewFF1WithAlphabet(key, alphabet, minMessageLength, maxMessageLength, maxTweakLength)
+		return &algorithm, err
+	case "ff3":
+		algorithm, err := fpe.NewFF3WithAlphabet(key, alphabet, minMessageLength, maxMessageLength)
+		return &algorithm, err
+	case "ff3-1":
+		algorithm, err := fpe.NewFF31WithAlphabet(key, alphabet, minMessageLength, maxMessageLength)
+		return &algorithm, err
+	case "small-domain":
+		algorithm, warning, err := fpe.NewSmallDomainWithAlphabet(key, alphabet, minMessageLength, maxMessageLength, maxTweakLength)
+		logWarning(warning, err)
+		return &algorithm, err
+	}
+	return nil, fmt.Errorf("unknown algorithm type %q", algorithmType)
+}
+
+// logWarning logs the security trade-off of an algorithm that was constructed
+// without error, so whoever configured the ark can see it.
+func logWarning(warning fpe.Warning, err error) {
+	if err == nil && warning != "" {
+		log.Printf("warning: %s\n", warning)
+	}
+}
+
+// getenv returns the value of an environment variable, or fallback if it is
+// not set.
+func getenv(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// writeNewKeyfile generates a new service key and writes it to a new keyfile,
+// encrypted by the key provider. It will not overwrite an existing keyfile.
+func writeNewKeyfile(path string) error {
+	key, err := newDataKey()
+	if err != nil {
+		return err
+	}
+	defer fpe.ZeroBytes(key)
+	encryptedKey, err := keyProvider.Encrypt(key)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(encryptedKey); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
 func updateArks() {
 
 }
 
 func main() {
-	awsCredentials := credentials.NewEnvCredentials()
-	conf, _ := goose.NewDBConf("db", "production", "")
-	dbConf = *conf
-	_, err := awsCredentials.Get()
-	if err != nil {
-		awsCredentials = credentials.NewSharedCredentials("", "format-preserving-encryption")
-		conf, _ = goose.NewDBConf("db", "development", "")
-		dbConf = *conf
+	if len(os.Args) > 1 && os.Args[1] == "new-local-key" {
+		contents, err := newLocalKeyFile(os.Getenv("LOCAL_KEY_PASSPHRASE"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		os.Stdout.Write(contents)
+		return
 	}
 
-	kmsClient := kms.New(session.New(&aws.Config{
-		Region:      aws.String("us-west-2"),
-		Credentials: awsCredentials,
-	}))
+	dbEnvironment := "production"
+	var err error
+	switch provider := getenv("KEY_PROVIDER", "kms"); strings.ToLower(provider) {
+	case "kms":
+		awsCredentials := credentials.NewEnvCredentials()
+		_, err = awsCredentials.Get()
+		if err != nil {
+			awsCredentials = credentials.NewSharedCredentials("", "format-preserving-encryption")
+			dbEnvironment = "development"
+		}
+		kmsClient := kms.New(session.New(&aws.Config{
+			Region:      aws.String(getenv("AWS_REGION", "us-west-2")),
+			Credentials: awsCredentials,
+		}))
+		keyProvider = &kmsKeyProvider{client: kmsClient, keyID: os.Getenv("KMS_KEY_ID")}
+	case "local":
+		dbEnvironment = "development"
+		keyProvider, err = newLocalKeyProvider(getenv("LOCAL_KEY_FILE", "./localkey"), os.Getenv("LOCAL_KEY_PASSPHRASE"))
+		if err != nil {
+			log.Fatal(err)
+		}
+	default:
+		log.Fatalf("unknown key provider %q", provider)
+	}
 
-	absPath, err := filepath.Abs("./keyfile")
+	conf, err := goose.NewDBConf("db", getenv("DB_ENV", dbEnvironment), "")
 	if err != nil {
 		log.Fatal(err)
 	}
+	dbConf = *conf
 
-	encryptedKey, err := ioutil.ReadFile(absPath)
+	absPath, err := filepath.Abs(getenv("KEY_FILE", "./keyfile"))
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	params := &kms.DecryptInput{
-		CiphertextBlob: encryptedKey,
+	if len(os.Args) > 1 && os.Args[1] == "new-keyfile" {
+		if err := writeNewKeyfile(absPath); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	encryptedKey, err := ioutil.ReadFile(absPath)
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	decryptOutput, err := kmsClient.Decrypt(params)
+	decryptedKey, err := keyProvider.Decrypt(encryptedKey)
 	if err != nil {
 		log.Fatal(err)
 	}
-	serviceKey = hex.EncodeToString(decryptOutput.Plaintext)
+	serviceKey = hex.EncodeToString(decryptedKey)
+	fpe.ZeroBytes(decryptedKey)
+
+	if name := os.Getenv("DOMAIN_POLICY"); name != "" {
+		domainPolicy, err = fpe.ParseDomainPolicy(name)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if overlap := os.Getenv("KEY_OVERLAP"); overlap != "" {
+		keyOverlap, err = time.ParseDuration(overlap)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if ttl := os.Getenv("ARK_CACHE_TTL"); ttl != "" {
+		arkCacheTTL, err = time.ParseDuration(ttl)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if workers := os.Getenv("BATCH_WORKERS"); workers != "" {
+		batchWorkers, err = strconv.Atoi(workers)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
 
 	secureMiddleware := secure.New(secure.Options{
 		FrameDeny:        true,
@@ -395,6 +1700,16 @@ func main() {
 		r.Post("/encrypt", PostEncryptHandler)
 		r.Get("/decrypt", GetDecryptHandler)
 		r.Post("/decrypt", PostDecryptHandler)
+		r.Post("/translate", TranslateHandler)
+	})
+
+	r.Route("/v1/admin", func(r chi.Router) {
+		r.Use(AdminKeyValid)
+
+		r.Get("/domain-policy", DomainPolicyReportHandler)
+		r.Post("/ark", CreateArkHandler)
+		r.Delete("/ark/{arkName}/key", DestroyArkKeyHandler)
+		r.Post("/ark/{arkName}/rotate", RotateArkKeyHandler)
 	})
 
 	r.Get("/health", Health)
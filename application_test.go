@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/unitehere/format-preserving-encryption/fpe"
+)
+
+func TestTranslatingAlgorithm(t *testing.T) {
+	t.Log("Testing translatingAlgorithm moves messages between keys and back... ")
+	from, err := fpe.NewFF1("2B7E151628AED2A6ABF7158809CF4F3C", 10, 2, 20, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	to, err := fpe.NewFF1("EF4359D8D580AA4F7F036D6F04FC6A94", 10, 2, 20, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	translating := &translatingAlgorithm{from: &from, to: &to}
+	tweak := []byte{0x39, 0x38, 0x37, 0x36}
+
+	stored, err := from.Encrypt("0123456789", tweak)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := to.Encrypt("0123456789", tweak)
+	if err != nil {
+		t.Fatal(err)
+	}
+	translated, err := translating.Encrypt(stored, tweak)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if translated != expected {
+		t.Errorf("Expected %s, got %s", expected, translated)
+	}
+	restored, err := translating.Decrypt(translated, tweak)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored != stored {
+		t.Errorf("Expected %s, got %s", stored, restored)
+	}
+}
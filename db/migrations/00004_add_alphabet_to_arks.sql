@@ -0,0 +1,6 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+ALTER TABLE arks ADD COLUMN alphabet varchar(255) CHARACTER SET utf8mb4;
+-- +goose Down
+ALTER TABLE arks DROP COLUMN alphabet;
+-- SQL in this section is executed when the migration is rolled back.
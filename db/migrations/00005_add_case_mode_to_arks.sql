@@ -0,0 +1,6 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+ALTER TABLE arks ADD COLUMN case_mode varchar(16) NOT NULL DEFAULT 'upper';
+-- +goose Down
+ALTER TABLE arks DROP COLUMN case_mode;
+-- SQL in this section is executed when the migration is rolled back.
@@ -0,0 +1,6 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+ALTER TABLE arks ADD COLUMN ark_type varchar(16) NOT NULL DEFAULT 'string';
+-- +goose Down
+ALTER TABLE arks DROP COLUMN ark_type;
+-- SQL in this section is executed when the migration is rolled back.
@@ -0,0 +1,6 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+ALTER TABLE arks ADD COLUMN range_min decimal(65,0), ADD COLUMN range_max decimal(65,0);
+-- +goose Down
+ALTER TABLE arks DROP COLUMN range_min, DROP COLUMN range_max;
+-- SQL in this section is executed when the migration is rolled back.
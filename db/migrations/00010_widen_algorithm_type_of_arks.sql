@@ -0,0 +1,6 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+ALTER TABLE arks MODIFY COLUMN algorithm_type varchar(16) NOT NULL;
+-- +goose Down
+ALTER TABLE arks MODIFY COLUMN algorithm_type varchar(5) NOT NULL;
+-- SQL in this section is executed when the migration is rolled back.
@@ -0,0 +1,9 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+-- Existing arks keep the shared service key their values were encrypted with,
+-- while new arks get a key derived for them alone.
+ALTER TABLE arks ADD COLUMN key_source varchar(16) NOT NULL DEFAULT 'shared', ADD COLUMN key_version int NOT NULL DEFAULT 1;
+ALTER TABLE arks ALTER COLUMN key_source SET DEFAULT 'derived';
+-- +goose Down
+ALTER TABLE arks DROP COLUMN key_source, DROP COLUMN key_version;
+-- SQL in this section is executed when the migration is rolled back.
@@ -0,0 +1,6 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+ALTER TABLE arks ADD COLUMN wrapped_key varbinary(1024);
+-- +goose Down
+ALTER TABLE arks DROP COLUMN wrapped_key;
+-- SQL in this section is executed when the migration is rolled back.
@@ -0,0 +1,15 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+-- Rotating the key of an ark moves its old key version here, where it can
+-- still decrypt until expires_at.
+CREATE TABLE ark_keys (
+  ark_name varchar(255) NOT NULL,
+  key_version INT NOT NULL,
+  key_source varchar(16) NOT NULL,
+  wrapped_key varbinary(1024),
+  expires_at DATETIME NOT NULL,
+  PRIMARY KEY (ark_name, key_version)
+);
+-- +goose Down
+DROP TABLE IF EXISTS ark_keys;
+-- SQL in this section is executed when the migration is rolled back.
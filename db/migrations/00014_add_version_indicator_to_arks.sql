@@ -0,0 +1,8 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+ALTER TABLE arks ADD COLUMN version_indicator_width INT NOT NULL DEFAULT 0;
+ALTER TABLE arks ADD COLUMN version_indicator_alphabet varchar(255) CHARACTER SET utf8mb4;
+-- +goose Down
+ALTER TABLE arks DROP COLUMN version_indicator_alphabet;
+ALTER TABLE arks DROP COLUMN version_indicator_width;
+-- SQL in this section is executed when the migration is rolled back.
@@ -0,0 +1,11 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+-- Databases migrated while this was version 00009 already have the column.
+SET @is_admin_exists = (SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = 'api_keys' AND column_name = 'is_admin');
+SET @add_is_admin = IF(@is_admin_exists = 0, 'ALTER TABLE api_keys ADD COLUMN is_admin boolean NOT NULL DEFAULT false', 'DO 0');
+PREPARE add_is_admin FROM @add_is_admin;
+EXECUTE add_is_admin;
+DEALLOCATE PREPARE add_is_admin;
+-- +goose Down
+ALTER TABLE api_keys DROP COLUMN is_admin;
+-- SQL in this section is executed when the migration is rolled back.
@@ -0,0 +1,7 @@
+-- +goose Up
+-- SQL in this section is executed when the migration is applied.
+-- An alphabet can have up to 65536 characters of up to 4 bytes each.
+ALTER TABLE arks MODIFY COLUMN alphabet MEDIUMTEXT CHARACTER SET utf8mb4;
+-- +goose Down
+ALTER TABLE arks MODIFY COLUMN alphabet varchar(255) CHARACTER SET utf8mb4;
+-- SQL in this section is executed when the migration is rolled back.
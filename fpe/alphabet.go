@@ -0,0 +1,101 @@
+package fpe
+
+import (
+	"errors"
+	"unicode"
+	"unicode/utf8"
+)
+
+// digits are the characters of the default alphabet, which is the first radix
+// characters of this string. This follows the digits used by math/big.
+const digits = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// caseInsensitiveRadix is the largest radix whose default alphabet ignores the
+// case of letters.
+const caseInsensitiveRadix = 36
+
+// The Alphabet type maps the characters of a message to the numerals that FF1
+// and FF3 operate on. The first character of the alphabet is the numeral 0,
+// the second is 1, and so on, so the radix is the number of characters. See
+// NewAlphabet, NewFF1WithAlphabet and NewFF3WithAlphabet for more detail.
+type Alphabet struct {
+	characters []rune
+	numerals   map[rune]uint16
+}
+
+// NewAlphabet returns a new Alphabet made of the characters of the given
+// string, in order. It returns an error if a character appears more than
+// once or if there are not between 2 and 65536 characters.
+func NewAlphabet(characters string) (alphabet Alphabet, err error) {
+	if !utf8.ValidString(characters) {
+		return Alphabet{}, errors.New("alphabet was not valid UTF-8")
+	}
+
+	runes := []rune(characters)
+	if len(runes) < 2 || len(runes) > 65536 {
+		return Alphabet{}, errors.New("alphabet must have between 2 and 65536 characters")
+	}
+
+	numerals := make(map[rune]uint16, len(runes))
+	for i, r := range runes {
+		if _, found := numerals[r]; found {
+			return Alphabet{}, errors.New("alphabet contained a duplicate character")
+		}
+		numerals[r] = uint16(i)
+	}
+
+	return Alphabet{characters: runes, numerals: numerals}, nil
+}
+
+// defaultAlphabet returns the alphabet used by NewFF1 and NewFF3, which is the
+// first radix characters of 0-9a-zA-Z. Like math/big, a radix up to 36 also
+// accepts the upper case form of each letter, while a radix from 37 to 62 is
+// case sensitive. It returns nil if the radix is above 62.
+func defaultAlphabet(radix int) *Alphabet {
+	if radix > len(digits) {
+		return nil
+	}
+
+	alphabet, _ := NewAlphabet(digits[:radix])
+	if radix <= caseInsensitiveRadix {
+		for i, r := range alphabet.characters {
+			alphabet.numerals[unicode.ToUpper(r)] = uint16(i)
+		}
+	}
+	return &alphabet
+}
+
+// Radix returns the number of characters in the alphabet.
+func (alphabet Alphabet) Radix() int {
+	return len(alphabet.characters)
+}
+
+// String returns the characters of the alphabet, in order.
+func (alphabet Alphabet) String() string {
+	return string(alphabet.characters)
+}
+
+// toNumerals returns the numerals of a message written in the alphabet. It
+// returns an error if the message contains a character that is not in the
+// alphabet.
+func (alphabet Alphabet) toNumerals(message string) ([]uint16, error) {
+	numerals := make([]uint16, 0, len(message))
+	for _, r := range message {
+		numeral, found := alphabet.numerals[r]
+		if !found {
+			return nil, errors.New("message contained a character that is not in the alphabet")
+		}
+		numerals = append(numerals, numeral)
+	}
+	return numerals, nil
+}
+
+// fromNumerals returns the message written in the alphabet for a slice of
+// numerals, each of which must be less than the radix.
+func (alphabet Alphabet) fromNumerals(numerals []uint16) string {
+	message := make([]rune, len(numerals))
+	for i, numeral := range numerals {
+		message[i] = alphabet.characters[numeral]
+	}
+	return string(message)
+}
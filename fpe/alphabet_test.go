@@ -0,0 +1,139 @@
+package fpe
+
+import (
+	"testing"
+)
+
+func TestNewAlphabetWithDuplicate(t *testing.T) {
+	t.Log("Testing NewAlphabet with a duplicate character... ")
+	_, err := NewAlphabet("ABCA")
+	assertError(t, err)
+}
+
+func TestNewAlphabetTooShort(t *testing.T) {
+	t.Log("Testing NewAlphabet with a single character... ")
+	_, err := NewAlphabet("A")
+	assertError(t, err)
+}
+
+func TestNewAlphabetTooLong(t *testing.T) {
+	t.Log("Testing NewAlphabet with more than 65536 characters... ")
+	characters := make([]rune, 65537)
+	for i := range characters {
+		characters[i] = rune(0x10000 + i)
+	}
+	_, err := NewAlphabet(string(characters))
+	assertError(t, err)
+}
+
+func TestFF1WithBase62Alphabet(t *testing.T) {
+	t.Log("Testing FF1 round trip with a 62 character alphabet... ")
+	alphabet, err := NewAlphabet("0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
+	assertNoError(t, err)
+	ff1, err := NewFF1WithAlphabet("2B7E151628AED2A6ABF7158809CF4F3C", alphabet, 2, 30, 16)
+	assertNoError(t, err)
+	msg, err := ff1.Encrypt("0123456789abcdefghij", []byte{})
+	assertNoError(t, err)
+	assertExpectedResult(t, "ytOxfe2ZTlG9X6y5WFy0", msg)
+	plaintext, err := ff1.Decrypt(msg, []byte{})
+	assertNoError(t, err)
+	assertExpectedResult(t, "0123456789abcdefghij", plaintext)
+}
+
+func TestFF1EncryptWithAlphabet(t *testing.T) {
+	t.Log("Testing FF1 encryption with a letters only alphabet... ")
+	alphabet, err := NewAlphabet("ABCDEFGHIJ")
+	assertNoError(t, err)
+	ff1, err := NewFF1WithAlphabet("2B7E151628AED2A6ABF7158809CF4F3C", alphabet, 2, 20, 16)
+	assertNoError(t, err)
+	msg, err := ff1.Encrypt("ABCDEFGHIJ", []byte{})
+	assertNoError(t, err)
+	assertExpectedResult(t, "CEDDEHHEIE", msg)
+}
+
+func TestFF1DecryptWithAlphabet(t *testing.T) {
+	t.Log("Testing FF1 decryption with a letters only alphabet... ")
+	alphabet, err := NewAlphabet("ABCDEFGHIJ")
+	assertNoError(t, err)
+	ff1, err := NewFF1WithAlphabet("2B7E151628AED2A6ABF7158809CF4F3C", alphabet, 2, 20, 16)
+	assertNoError(t, err)
+	plaintext, err := ff1.Decrypt("CEDDEHHEIE", []byte{})
+	assertNoError(t, err)
+	assertExpectedResult(t, "ABCDEFGHIJ", plaintext)
+}
+
+func TestFF1WithAlphabetRoundTrip(t *testing.T) {
+	t.Log("Testing FF1 round trip with an alphabet without confusable characters... ")
+	alphabet, err := NewAlphabet("ABCDEFGHJKLMNPQRSTUVWXYZ23456789")
+	assertNoError(t, err)
+	ff1, err := NewFF1WithAlphabet("2B7E151628AED2A6ABF7158809CF4F3C", alphabet, 2, 20, 16)
+	assertNoError(t, err)
+	msg, err := ff1.Encrypt("HX7K2MZQ", []byte{0x01, 0x02})
+	assertNoError(t, err)
+	for _, r := range msg {
+		if _, found := alphabet.numerals[r]; !found {
+			t.Errorf("Encrypted message \"%s\" contained a character outside the alphabet.", msg)
+		}
+	}
+	plaintext, err := ff1.Decrypt(msg, []byte{0x01, 0x02})
+	assertNoError(t, err)
+	assertExpectedResult(t, "HX7K2MZQ", plaintext)
+}
+
+func TestFF1EncryptWithAlphabetInvalidMessage(t *testing.T) {
+	t.Log("Testing FF1 encryption with a character outside the alphabet... ")
+	alphabet, err := NewAlphabet("ABCDEFGHJKLMNPQRSTUVWXYZ23456789")
+	assertNoError(t, err)
+	ff1, err := NewFF1WithAlphabet("2B7E151628AED2A6ABF7158809CF4F3C", alphabet, 2, 20, 16)
+	assertNoError(t, err)
+	_, err = ff1.Encrypt("HX7K1MZQ", []byte{})
+	assertError(t, err)
+}
+
+func TestFF3EncryptWithAlphabet(t *testing.T) {
+	t.Log("Testing FF3 encryption with a letters only alphabet... ")
+	alphabet, err := NewAlphabet("ABCDEFGHIJ")
+	assertNoError(t, err)
+	ff3, err := NewFF3WithAlphabet("EF4359D8D580AA4F7F036D6F04FC6A94", alphabet, 2, 20)
+	assertNoError(t, err)
+	msg, err := ff3.Encrypt("IJABCBCDEFGHIJAAAA", []byte{0xD8, 0xE7, 0x92, 0x0A, 0xFA, 0x33, 0x0A, 0x73})
+	assertNoError(t, err)
+	assertExpectedResult(t, "HFAJBIIBEAFIGFEGAH", msg)
+}
+
+func TestFF3DecryptWithAlphabet(t *testing.T) {
+	t.Log("Testing FF3 decryption with a letters only alphabet... ")
+	alphabet, err := NewAlphabet("ABCDEFGHIJ")
+	assertNoError(t, err)
+	ff3, err := NewFF3WithAlphabet("EF4359D8D580AA4F7F036D6F04FC6A94", alphabet, 2, 20)
+	assertNoError(t, err)
+	plaintext, err := ff3.Decrypt("HFAJBIIBEAFIGFEGAH", []byte{0xD8, 0xE7, 0x92, 0x0A, 0xFA, 0x33, 0x0A, 0x73})
+	assertNoError(t, err)
+	assertExpectedResult(t, "IJABCBCDEFGHIJAAAA", plaintext)
+}
+
+func TestFF1EncryptRadix62CaseSensitive(t *testing.T) {
+	t.Log("Testing FF1 encryption with radix 62 and the default alphabet... ")
+	ff1, err := NewFF1("2B7E151628AED2A6ABF7158809CF4F3C", 62, 2, 30, 16)
+	assertNoError(t, err)
+	msg, err := ff1.Encrypt("0123456789abcdefghij", []byte{})
+	assertNoError(t, err)
+	assertExpectedResult(t, "ytOxfe2ZTlG9X6y5WFy0", msg)
+	plaintext, err := ff1.Decrypt("ytOxfe2ZTlG9X6y5WFy0", []byte{})
+	assertNoError(t, err)
+	assertExpectedResult(t, "0123456789abcdefghij", plaintext)
+	plaintext, err = ff1.Decrypt("YTOXFE2ZTLG9X6Y5WFY0", []byte{})
+	assertNoError(t, err)
+	if plaintext == "0123456789abcdefghij" {
+		t.Errorf("Expected radix 62 decryption to depend on the case of letters.")
+	}
+}
+
+func TestFF1DecryptRadix36IgnoresCase(t *testing.T) {
+	t.Log("Testing FF1 decryption with radix 36 and upper case input... ")
+	ff1, err := NewFF1("2B7E151628AED2A6ABF7158809CF4F3C", 36, 2, 20, 16)
+	assertNoError(t, err)
+	plaintext, err := ff1.Decrypt("A9TV40MLL9KDU509EUM", []byte{0x37, 0x37, 0x37, 0x37, 0x70, 0x71, 0x72, 0x73, 0x37, 0x37, 0x37})
+	assertNoError(t, err)
+	assertExpectedResult(t, "0123456789abcdefghi", plaintext)
+}
@@ -13,6 +13,19 @@ func assertExpectedResult(t *testing.T, expected, actual string) {
 	}
 }
 
+func assertExpectedNumerals(t *testing.T, expected, actual []uint16) {
+	if len(expected) != len(actual) {
+		t.Errorf("Expected result of %v, but it was %v instead.", expected, actual)
+		return
+	}
+	for i := range expected {
+		if expected[i] != actual[i] {
+			t.Errorf("Expected result of %v, but it was %v instead.", expected, actual)
+			return
+		}
+	}
+}
+
 func assertError(t *testing.T, err error) {
 	if err == nil {
 		t.Errorf("Expected an error but received none.")
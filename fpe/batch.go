@@ -0,0 +1,84 @@
+package fpe
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// EncryptBatch encrypts each of the plaintexts with algorithm, spreading the
+// work over at most workers goroutines, or runtime.GOMAXPROCS(0) of them if
+// workers is 0 or less. With one worker the batch is encrypted on the calling
+// goroutine.
+// The tweaks argument gives the tweak of each plaintext by index. It may be
+// shorter than plaintexts, in which case the rest use an empty tweak.
+// The messages and errs results are in the order of plaintexts, with errs[i]
+// set if plaintexts[i] could not be encrypted. If ctx is done before the batch
+// is finished, the remaining plaintexts are skipped, their errs are set to
+// ctx.Err(), and it is also returned as err.
+func EncryptBatch(ctx context.Context, algorithm Algorithm, plaintexts []string, tweaks [][]byte, workers int) (messages []string, errs []error, err error) {
+	return runBatch(ctx, algorithm.Encrypt, plaintexts, tweaks, workers)
+}
+
+// DecryptBatch decrypts each of the messages with algorithm like EncryptBatch.
+// The plaintexts and errs results are in the order of messages.
+func DecryptBatch(ctx context.Context, algorithm Algorithm, messages []string, tweaks [][]byte, workers int) (plaintexts []string, errs []error, err error) {
+	return runBatch(ctx, algorithm.Decrypt, messages, tweaks, workers)
+}
+
+// Utility Functions for batches
+
+// runBatch applies cipher to each of the inputs. The workers claim the next
+// input from a shared counter, so once they stop every input before the
+// counter has been done and every input from it on was skipped because ctx
+// was done.
+func runBatch(ctx context.Context, cipher func(string, []byte) (string, error), inputs []string, tweaks [][]byte, workers int) ([]string, []error, error) {
+	outputs := make([]string, len(inputs))
+	errs := make([]error, len(inputs))
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > len(inputs) {
+		workers = len(inputs)
+	}
+
+	var next int64
+	work := func() {
+		for ctx.Err() == nil {
+			i := int(atomic.AddInt64(&next, 1) - 1)
+			if i >= len(inputs) {
+				return
+			}
+			tweak := []byte{}
+			if i < len(tweaks) {
+				tweak = tweaks[i]
+			}
+			outputs[i], errs[i] = cipher(inputs[i], tweak)
+		}
+	}
+
+	if workers <= 1 {
+		work()
+	} else {
+		var wg sync.WaitGroup
+		wg.Add(workers)
+		for w := 0; w < workers; w++ {
+			go func() {
+				defer wg.Done()
+				work()
+			}()
+		}
+		wg.Wait()
+	}
+
+	done := int(atomic.LoadInt64(&next))
+	if done >= len(inputs) {
+		return outputs, errs, nil
+	}
+	err := ctx.Err()
+	for i := done; i < len(inputs); i++ {
+		errs[i] = err
+	}
+	return outputs, errs, err
+}
@@ -0,0 +1,128 @@
+package fpe
+
+import (
+	"context"
+	"strconv"
+	"testing"
+)
+
+// cancellingAlgorithm returns messages as they are, counting its calls, and
+// cancels its context once it has been called after times.
+type cancellingAlgorithm struct {
+	cancel context.CancelFunc
+	after  int
+	calls  chan struct{}
+}
+
+func (algorithm *cancellingAlgorithm) Encrypt(plaintext string, tweak []byte) (string, error) {
+	algorithm.calls <- struct{}{}
+	if len(algorithm.calls) >= algorithm.after {
+		algorithm.cancel()
+	}
+	return plaintext, nil
+}
+
+func (algorithm *cancellingAlgorithm) Decrypt(message string, tweak []byte) (string, error) {
+	return algorithm.Encrypt(message, tweak)
+}
+
+func batchPlaintexts(n int) []string {
+	plaintexts := make([]string, n)
+	for i := range plaintexts {
+		plaintexts[i] = strconv.Itoa(100000000 + i*7919)
+	}
+	return plaintexts
+}
+
+func TestEncryptBatch(t *testing.T) {
+	t.Log("Testing EncryptBatch returns results in input order... ")
+	ff1, err := NewFF1("2B7E151628AED2A6ABF7158809CF4F3C", 10, 9, 9, 16)
+	assertNoError(t, err)
+	plaintexts := batchPlaintexts(500)
+	tweaks := [][]byte{{0x39, 0x38}, {0x37}}
+	for _, workers := range []int{0, 1, 4} {
+		messages, errs, err := EncryptBatch(context.Background(), &ff1, plaintexts, tweaks, workers)
+		assertNoError(t, err)
+		for i, plaintext := range plaintexts {
+			tweak := []byte{}
+			if i < len(tweaks) {
+				tweak = tweaks[i]
+			}
+			expected, _ := ff1.Encrypt(plaintext, tweak)
+			assertNoError(t, errs[i])
+			assertExpectedResult(t, expected, messages[i])
+		}
+
+		decrypted, errs, err := DecryptBatch(context.Background(), &ff1, messages, tweaks, workers)
+		assertNoError(t, err)
+		for i, plaintext := range plaintexts {
+			assertNoError(t, errs[i])
+			assertExpectedResult(t, plaintext, decrypted[i])
+		}
+	}
+}
+
+func TestEncryptBatchItemErrors(t *testing.T) {
+	t.Log("Testing EncryptBatch reports the errors of each message... ")
+	ff1, err := NewFF1("2B7E151628AED2A6ABF7158809CF4F3C", 10, 9, 9, 16)
+	assertNoError(t, err)
+	plaintexts := batchPlaintexts(100)
+	plaintexts[3] = "12345"
+	plaintexts[71] = "12345678X"
+	messages, errs, err := EncryptBatch(context.Background(), &ff1, plaintexts, nil, 4)
+	assertNoError(t, err)
+	for i := range plaintexts {
+		if i == 3 || i == 71 {
+			assertError(t, errs[i])
+			continue
+		}
+		assertNoError(t, errs[i])
+		if len(messages[i]) != 9 {
+			t.Errorf("Expected a 9 digit message, got %q", messages[i])
+		}
+	}
+}
+
+func TestEncryptBatchCancelled(t *testing.T) {
+	t.Log("Testing EncryptBatch skips the rest of a batch once cancelled... ")
+	ctx, cancel := context.WithCancel(context.Background())
+	algorithm := &cancellingAlgorithm{cancel: cancel, after: 10, calls: make(chan struct{}, 1000)}
+	plaintexts := batchPlaintexts(1000)
+	messages, errs, err := EncryptBatch(ctx, algorithm, plaintexts, nil, 4)
+	if err != context.Canceled {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	calls := len(algorithm.calls)
+	if calls >= len(plaintexts) {
+		t.Fatalf("Expected the batch to stop early, got %d calls", calls)
+	}
+	for i := range plaintexts {
+		if i < calls {
+			assertNoError(t, errs[i])
+			assertExpectedResult(t, plaintexts[i], messages[i])
+		} else if errs[i] != context.Canceled {
+			t.Fatalf("Expected context.Canceled for message %d, got %v", i, errs[i])
+		}
+	}
+}
+
+func TestEncryptBatchAlreadyCancelled(t *testing.T) {
+	t.Log("Testing EncryptBatch does nothing with a cancelled context... ")
+	ff1, err := NewFF1("2B7E151628AED2A6ABF7158809CF4F3C", 10, 9, 9, 16)
+	assertNoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, errs, err := EncryptBatch(ctx, &ff1, batchPlaintexts(10), nil, 1)
+	if err != context.Canceled || errs[0] != context.Canceled || errs[9] != context.Canceled {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func BenchmarkEncryptBatch(b *testing.B) {
+	ff1, _ := NewFF1("2B7E151628AED2A6ABF7158809CF4F3C", 10, 9, 9, 16)
+	plaintexts := batchPlaintexts(1000)
+
+	for i := 0; i < b.N; i++ {
+		EncryptBatch(context.Background(), &ff1, plaintexts, nil, 0)
+	}
+}
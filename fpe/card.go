@@ -0,0 +1,134 @@
+package fpe
+
+import (
+	"errors"
+	"math/big"
+)
+
+const (
+	// cardPrefixLength is the number of leading digits, the bank
+	// identification number, that are kept in place.
+	cardPrefixLength = 6
+	// cardSuffixLength is the number of trailing digits that are kept in
+	// place. They include the Luhn check digit.
+	cardSuffixLength = 4
+	// minCardLength and maxCardLength bound the length of card numbers, which
+	// leaves between 2 and 9 middle digits to encrypt.
+	minCardLength = 12
+	maxCardLength = 19
+	// maxCardIterations caps the cycle walk. A tenth of the middle digits pass
+	// the Luhn check, so a walk takes 10 iterations on average.
+	maxCardIterations = 1000
+)
+
+// The CardNumber type allows for encryption and decryption of payment card
+// numbers. The first six and last four digits are kept in place and the
+// middle digits are encrypted with FF1, so the result is still a card number
+// with the same bank identification number that passes the Luhn check. See the
+// NewCardNumber, (card *CardNumber) Encrypt, and (card *CardNumber) Decrypt
+// functions for more detail.
+type CardNumber struct {
+	ff1 FF1
+	// minLength and maxLength are the shortest and longest card numbers
+	// accepted.
+	minLength int
+	maxLength int
+}
+
+// NewCardNumber returns a new CardNumber struct for encrypting and decrypting
+// card numbers of 12 to 19 digits. It will also return any errors encountered
+// in creating an AES key.
+// The keyString argument should be the AES key string in hexadecimal, either
+// 16, 24, or 32 bytes to select AES-128, AES-192, or AES-256.
+// The maxTweakLength argument should be the maximum length of tweaks, in bytes.
+func NewCardNumber(keyString string, maxTweakLength int) (card CardNumber, err error) {
+	return NewCardNumberWithLengths(keyString, minCardLength, maxCardLength, maxTweakLength)
+}
+
+// NewCardNumberWithLengths returns a new CardNumber struct like NewCardNumber,
+// but only accepting card numbers of minLength to maxLength digits, which must
+// be between 12 and 19. A card number encrypts the same whatever lengths are
+// accepted, but the shortest length sets the minimum domain size.
+func NewCardNumberWithLengths(keyString string, minLength, maxLength, maxTweakLength int) (card CardNumber, err error) {
+	if minLength < minCardLength || maxLength > maxCardLength || minLength > maxLength {
+		return CardNumber{}, errors.New("card number lengths must be between 12 and 19 digits")
+	}
+	ff1, err := NewFF1(keyString, 10, minLength-cardPrefixLength-cardSuffixLength,
+		maxLength-cardPrefixLength-cardSuffixLength, maxTweakLength)
+	if err != nil {
+		return CardNumber{}, err
+	}
+
+	return CardNumber{ff1: ff1, minLength: minLength, maxLength: maxLength}, nil
+}
+
+// MinimumDomainSize returns the number of middle digits of the shortest card
+// numbers accepted that pass the Luhn check for a given prefix and suffix,
+// which is a tenth of 10^(minLength-10).
+func (card *CardNumber) MinimumDomainSize() *big.Int {
+	return new(big.Int).Div(card.ff1.MinimumDomainSize(), big.NewInt(10))
+}
+
+// Encrypt encrypts the middle digits of a card number. It returns the
+// encrypted card number, which keeps the first six and last four digits and
+// passes the Luhn check, along with any error encountered during encryption.
+// The plaintext argument should be a card number of an accepted length that
+// passes the Luhn check.
+// The tweak argument should be the tweak to use in the encryption process.
+func (card *CardNumber) Encrypt(plaintext string, tweak []byte) (message string, err error) {
+	return card.walk(plaintext, tweak, card.ff1.Encrypt)
+}
+
+// Decrypt decrypts the middle digits of a card number. It returns the
+// decrypted card number, along with any error encountered during decryption.
+// The message argument should be a card number returned by Encrypt.
+// The tweak argument should be the tweak to use in the decryption process.
+func (card *CardNumber) Decrypt(message string, tweak []byte) (plaintext string, err error) {
+	return card.walk(message, tweak, card.ff1.Decrypt)
+}
+
+// Utility Functions for CardNumber
+
+// walk checks a card number and cycle walks its middle digits with cipher
+// until the card number passes the Luhn check again.
+func (card *CardNumber) walk(number string, tweak []byte, cipher func(string, []byte) (string, error)) (string, error) {
+	if len(number) < card.minLength || len(number) > card.maxLength {
+		return "", errors.New("card number was not of an accepted length")
+	}
+	if !luhnValid(number) {
+		return "", errors.New("card number did not pass the Luhn check")
+	}
+
+	prefix := number[:cardPrefixLength]
+	middle := number[cardPrefixLength : len(number)-cardSuffixLength]
+	suffix := number[len(number)-cardSuffixLength:]
+	middle, err := walkUntil(middle, tweak, cipher, func(middle string) bool {
+		return luhnValid(prefix + middle + suffix)
+	}, maxCardIterations)
+	if err != nil {
+		return "", err
+	}
+	return prefix + middle + suffix, nil
+}
+
+// luhnValid reports whether a string of digits passes the Luhn check. It
+// returns false if the string contains anything other than digits.
+func luhnValid(number string) bool {
+	sum := 0
+	double := false
+	for i := len(number) - 1; i >= 0; i-- {
+		if number[i] < '0' || number[i] > '9' {
+			return false
+		}
+		digit := int(number[i] - '0')
+		if double {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		double = !double
+	}
+	return sum%10 == 0
+}
@@ -0,0 +1,89 @@
+package fpe
+
+import (
+	"testing"
+)
+
+func TestCardNumberEncrypt(t *testing.T) {
+	t.Log("Testing CardNumber encryption of a 16 digit card... ")
+	card, err := NewCardNumber("2B7E151628AED2A6ABF7158809CF4F3C", 16)
+	assertNoError(t, err)
+	msg, err := card.Encrypt("4111111111111111", []byte{})
+	assertNoError(t, err)
+	assertExpectedResult(t, "4111118544211111", msg)
+}
+
+func TestCardNumberDecrypt(t *testing.T) {
+	t.Log("Testing CardNumber decryption of a 16 digit card... ")
+	card, err := NewCardNumber("2B7E151628AED2A6ABF7158809CF4F3C", 16)
+	assertNoError(t, err)
+	plaintext, err := card.Decrypt("4111118544211111", []byte{})
+	assertNoError(t, err)
+	assertExpectedResult(t, "4111111111111111", plaintext)
+}
+
+func TestCardNumberWithTweak(t *testing.T) {
+	t.Log("Testing CardNumber round trip of a 15 digit card with a tweak... ")
+	card, err := NewCardNumber("2B7E151628AED2A6ABF7158809CF4F3C", 16)
+	assertNoError(t, err)
+	tweak := []byte{0x39, 0x38, 0x37, 0x36, 0x35, 0x34, 0x33, 0x32, 0x31, 0x30}
+	msg, err := card.Encrypt("378282246310005", tweak)
+	assertNoError(t, err)
+	assertExpectedResult(t, "378282284420005", msg)
+	plaintext, err := card.Decrypt(msg, tweak)
+	assertNoError(t, err)
+	assertExpectedResult(t, "378282246310005", plaintext)
+}
+
+func TestCardNumberKeepsLuhn(t *testing.T) {
+	t.Log("Testing CardNumber output passes the Luhn check... ")
+	card, err := NewCardNumber("2B7E151628AED2A6ABF7158809CF4F3C", 16)
+	assertNoError(t, err)
+	for _, number := range []string{"6011000990139424", "5555555555554444", "4000056655665556", "401288888881881"} {
+		msg, err := card.Encrypt(number, []byte{})
+		assertNoError(t, err)
+		if !luhnValid(msg) || msg[:6] != number[:6] || msg[len(msg)-4:] != number[len(number)-4:] {
+			t.Fatalf("Expected a Luhn valid card with the same first six and last four digits as %s, got %s", number, msg)
+		}
+		plaintext, err := card.Decrypt(msg, []byte{})
+		assertNoError(t, err)
+		assertExpectedResult(t, number, plaintext)
+	}
+}
+
+func TestCardNumberWithInvalidLuhn(t *testing.T) {
+	t.Log("Testing CardNumber encryption of a card that fails the Luhn check... ")
+	card, err := NewCardNumber("2B7E151628AED2A6ABF7158809CF4F3C", 16)
+	assertNoError(t, err)
+	_, err = card.Encrypt("4111111111111112", []byte{})
+	assertError(t, err)
+}
+
+func TestCardNumberWithInvalidCharacters(t *testing.T) {
+	t.Log("Testing CardNumber encryption of a card with separators... ")
+	card, err := NewCardNumber("2B7E151628AED2A6ABF7158809CF4F3C", 16)
+	assertNoError(t, err)
+	_, err = card.Encrypt("4111-1111-1111-1111", []byte{})
+	assertError(t, err)
+}
+
+func TestCardNumberTooShort(t *testing.T) {
+	t.Log("Testing CardNumber encryption of an 11 digit number... ")
+	card, err := NewCardNumber("2B7E151628AED2A6ABF7158809CF4F3C", 16)
+	assertNoError(t, err)
+	_, err = card.Encrypt("41111111113", []byte{})
+	assertError(t, err)
+}
+
+func TestCardNumberWithLengths(t *testing.T) {
+	t.Log("Testing CardNumber accepts only the lengths it was made for... ")
+	card, err := NewCardNumberWithLengths("2B7E151628AED2A6ABF7158809CF4F3C", 16, 16, 16)
+	assertNoError(t, err)
+	msg, err := card.Encrypt("4111111111111111", []byte{})
+	assertNoError(t, err)
+	assertExpectedResult(t, "4111118544211111", msg)
+	_, err = card.Encrypt("378282246310005", []byte{})
+	assertError(t, err)
+	_, err = NewCardNumberWithLengths("2B7E151628AED2A6ABF7158809CF4F3C", 11, 16, 16)
+	assertError(t, err)
+}
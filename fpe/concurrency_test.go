@@ -0,0 +1,119 @@
+package fpe
+
+import (
+	"sync"
+	"testing"
+)
+
+// These tests share one instance between many goroutines. Run them with
+// `go test -race` to have the race detector check for shared mutable state.
+
+const (
+	concurrentWorkers    = 32
+	concurrentIterations = 100
+)
+
+type concurrentCase struct {
+	plaintext  string
+	ciphertext string
+	tweak      []byte
+}
+
+func assertConcurrentRoundTrips(t *testing.T, algorithm Algorithm, cases []concurrentCase) {
+	var wg sync.WaitGroup
+	errs := make(chan string, concurrentWorkers*concurrentIterations)
+	for worker := 0; worker < concurrentWorkers; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for i := 0; i < concurrentIterations; i++ {
+				c := cases[(worker+i)%len(cases)]
+				msg, err := algorithm.Encrypt(c.plaintext, c.tweak)
+				if err != nil || msg != c.ciphertext {
+					errs <- "encrypt " + c.plaintext + " gave " + msg
+				}
+				plaintext, err := algorithm.Decrypt(c.ciphertext, c.tweak)
+				if err != nil || plaintext != c.plaintext {
+					errs <- "decrypt " + c.ciphertext + " gave " + plaintext
+				}
+			}
+		}(worker)
+	}
+	wg.Wait()
+	close(errs)
+	for e := range errs {
+		t.Error(e)
+	}
+}
+
+func TestFF1Concurrent(t *testing.T) {
+	t.Log("Testing FF1 encryption and decryption from many goroutines... ")
+	ff1, err := NewFF1("2B7E151628AED2A6ABF7158809CF4F3C", 10, 2, 20, 16)
+	assertNoError(t, err)
+	assertConcurrentRoundTrips(t, &ff1, []concurrentCase{
+		{"0123456789", "2433477484", []byte{}},
+		{"0123456789", "6124200773", []byte{0x39, 0x38, 0x37, 0x36, 0x35, 0x34, 0x33, 0x32, 0x31, 0x30}},
+	})
+}
+
+func TestFF3Concurrent(t *testing.T) {
+	t.Log("Testing FF3 encryption and decryption from many goroutines... ")
+	ff3, err := NewFF3("EF4359D8D580AA4F7F036D6F04FC6A94", 10, 2, 30)
+	assertNoError(t, err)
+	assertConcurrentRoundTrips(t, &ff3, []concurrentCase{
+		{"890121234567890000", "750918814058654607", []byte{0xD8, 0xE7, 0x92, 0x0A, 0xFA, 0x33, 0x0A, 0x73}},
+		{"890121234567890000", "018989839189395384", []byte{0x9A, 0x76, 0x8A, 0x92, 0xF6, 0x0E, 0x12, 0xD8}},
+		{"89012123456789000000789000000", "34695224821734535122613701434", []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}},
+	})
+}
+
+func TestFF31Concurrent(t *testing.T) {
+	t.Log("Testing FF3-1 encryption and decryption from many goroutines... ")
+	ff31, err := NewFF31("EF4359D8D580AA4F7F036D6F04FC6A94", 10, 2, 20)
+	assertNoError(t, err)
+	assertConcurrentRoundTrips(t, &ff31, []concurrentCase{
+		{"890121234567890000", "477064185124354662", []byte{0xD8, 0xE7, 0x92, 0x0A, 0xFA, 0x33, 0x0A}},
+	})
+}
+
+func TestSmallDomainConcurrent(t *testing.T) {
+	t.Log("Testing SmallDomain encryption and decryption from many goroutines... ")
+	smallDomain, _, err := NewSmallDomain("2B7E151628AED2A6ABF7158809CF4F3C", 10, 1, 2, 16)
+	assertNoError(t, err)
+	assertConcurrentRoundTrips(t, &smallDomain, []concurrentCase{
+		{"42", "67", []byte{}},
+		{"42", "82", []byte{0x39, 0x38, 0x37, 0x36, 0x35, 0x34, 0x33, 0x32, 0x31, 0x30}},
+		{"0", "6", []byte{}},
+	})
+}
+
+func TestFF1DestroyConcurrent(t *testing.T) {
+	t.Log("Testing FF1 Destroy while copies encrypt from many goroutines... ")
+	ff1, err := NewFF1("2B7E151628AED2A6ABF7158809CF4F3C", 10, 2, 20, 16)
+	assertNoError(t, err)
+	var wg sync.WaitGroup
+	errs := make(chan string, concurrentWorkers*concurrentIterations)
+	for worker := 0; worker < concurrentWorkers; worker++ {
+		wg.Add(1)
+		go func(copied FF1) {
+			defer wg.Done()
+			for i := 0; i < concurrentIterations; i++ {
+				msg, err := copied.Encrypt("0123456789", []byte{})
+				if err == nil && msg != "2433477484" {
+					errs <- "encrypt 0123456789 gave " + msg
+				} else if err != nil && err != ErrDestroyed {
+					errs <- "encrypt 0123456789 failed with " + err.Error()
+				}
+			}
+		}(ff1)
+	}
+	ff1.Destroy()
+	wg.Wait()
+	close(errs)
+	for e := range errs {
+		t.Error(e)
+	}
+	if _, err := ff1.Encrypt("0123456789", []byte{}); err != ErrDestroyed {
+		t.Errorf("Expected ErrDestroyed, got %v", err)
+	}
+}
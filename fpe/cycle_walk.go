@@ -0,0 +1,87 @@
+package fpe
+
+import (
+	"errors"
+)
+
+// ErrCycleWalkLimit is returned when a cycle walk does not reach a message
+// accepted by its predicate within the iteration limit. This means the
+// predicate accepts too small a share of the domain for the limit.
+var ErrCycleWalkLimit = errors.New("cycle walk did not reach an accepted message within the iteration limit")
+
+// The CycleWalk type wraps an Algorithm so that encryption and decryption stay
+// inside a subset of its messages, such as social security numbers that do not
+// start with 000 or 666, or IDs that do not start with 0. See the
+// NewCycleWalk, (cycleWalk *CycleWalk) Encrypt, and
+// (cycleWalk *CycleWalk) Decrypt functions for more detail.
+type CycleWalk struct {
+	algorithm     Algorithm
+	predicate     func(message string) bool
+	maxIterations int
+}
+
+// NewCycleWalk returns a new CycleWalk struct for encrypting and decrypting
+// the messages accepted by a predicate. It will also return any errors
+// encountered in checking its arguments.
+// The algorithm argument should be the algorithm to walk with, such as an FF1
+// or FF3-1.
+// The predicate argument should return whether a message is in the subset.
+// It must be safe for concurrent use.
+// The maxIterations argument should be the most times the algorithm is applied
+// to a message before giving up with ErrCycleWalkLimit. If the predicate
+// accepts a share p of the messages, a walk takes 1/p iterations on average.
+func NewCycleWalk(algorithm Algorithm, predicate func(message string) bool, maxIterations int) (cycleWalk CycleWalk, err error) {
+	if algorithm == nil || predicate == nil {
+		return CycleWalk{}, errors.New("cycle walk needs an algorithm and a predicate")
+	}
+	if maxIterations < 1 {
+		return CycleWalk{}, errors.New("cycle walk needs at least 1 iteration")
+	}
+
+	return CycleWalk{
+		algorithm:     algorithm,
+		predicate:     predicate,
+		maxIterations: maxIterations}, nil
+}
+
+// Encrypt encrypts a message accepted by the predicate. It returns the
+// encrypted message, which is also accepted by the predicate, along with any
+// error encountered during encryption.
+// The plaintext argument should be the message to encrypt.
+// The tweak argument should be the tweak to use in the encryption process.
+func (cycleWalk *CycleWalk) Encrypt(plaintext string, tweak []byte) (message string, err error) {
+	return walkUntil(plaintext, tweak, cycleWalk.algorithm.Encrypt, cycleWalk.predicate, cycleWalk.maxIterations)
+}
+
+// Decrypt decrypts a message accepted by the predicate. It returns the
+// decrypted message, along with any error encountered during decryption.
+// The message argument should be the message to decrypt.
+// The tweak argument should be the tweak to use in the decryption process.
+func (cycleWalk *CycleWalk) Decrypt(message string, tweak []byte) (plaintext string, err error) {
+	return walkUntil(message, tweak, cycleWalk.algorithm.Decrypt, cycleWalk.predicate, cycleWalk.maxIterations)
+}
+
+// Utility Functions for CycleWalk
+
+// walkUntil checks that predicate accepts message and applies cipher to it
+// until the result is accepted again, at most maxIterations times. Since
+// cipher is a permutation, this cycle walk is a permutation of the accepted
+// messages, and walking with the inverse of cipher undoes it in the same
+// number of iterations.
+func walkUntil(message string, tweak []byte, cipher func(string, []byte) (string, error), predicate func(string) bool, maxIterations int) (string, error) {
+	if !predicate(message) {
+		return "", errors.New("message was not accepted by the cycle walk predicate")
+	}
+
+	for i := 0; i < maxIterations; i++ {
+		var err error
+		message, err = cipher(message, tweak)
+		if err != nil {
+			return "", err
+		}
+		if predicate(message) {
+			return message, nil
+		}
+	}
+	return "", ErrCycleWalkLimit
+}
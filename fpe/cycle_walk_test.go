@@ -0,0 +1,87 @@
+package fpe
+
+import (
+	"testing"
+)
+
+// validSSN accepts social security numbers that do not start with 000, 666 or
+// 9, and do not have a group of 00 or a serial of 0000.
+func validSSN(message string) bool {
+	return message[:3] != "000" && message[:3] != "666" && message[0] != '9' &&
+		message[3:5] != "00" && message[5:] != "0000"
+}
+
+func TestCycleWalkEncrypt(t *testing.T) {
+	t.Log("Testing CycleWalk encryption of a social security number... ")
+	ff1, err := NewFF1("2B7E151628AED2A6ABF7158809CF4F3C", 10, 9, 9, 16)
+	assertNoError(t, err)
+	cycleWalk, err := NewCycleWalk(&ff1, validSSN, 100)
+	assertNoError(t, err)
+	msg, err := cycleWalk.Encrypt("078051120", []byte{})
+	assertNoError(t, err)
+	assertExpectedResult(t, "653918268", msg)
+}
+
+func TestCycleWalkDecrypt(t *testing.T) {
+	t.Log("Testing CycleWalk decryption of a social security number... ")
+	ff1, err := NewFF1("2B7E151628AED2A6ABF7158809CF4F3C", 10, 9, 9, 16)
+	assertNoError(t, err)
+	cycleWalk, err := NewCycleWalk(&ff1, validSSN, 100)
+	assertNoError(t, err)
+	plaintext, err := cycleWalk.Decrypt("653918268", []byte{})
+	assertNoError(t, err)
+	assertExpectedResult(t, "078051120", plaintext)
+}
+
+func TestCycleWalkWithFF31(t *testing.T) {
+	t.Log("Testing CycleWalk round trip of IDs that do not start with 0... ")
+	ff31, err := NewFF31("EF4359D8D580AA4F7F036D6F04FC6A94", 10, 2, 20)
+	assertNoError(t, err)
+	cycleWalk, err := NewCycleWalk(&ff31, func(message string) bool {
+		return message[0] != '0'
+	}, 100)
+	assertNoError(t, err)
+	tweak := []byte{0xD8, 0xE7, 0x92, 0x0A, 0xFA, 0x33, 0x0A}
+	for _, id := range []string{"1000", "1234567890", "9999999"} {
+		msg, err := cycleWalk.Encrypt(id, tweak)
+		assertNoError(t, err)
+		if msg[0] == '0' || len(msg) != len(id) {
+			t.Fatalf("Expected an ID of length %d that does not start with 0, got %s", len(id), msg)
+		}
+		plaintext, err := cycleWalk.Decrypt(msg, tweak)
+		assertNoError(t, err)
+		assertExpectedResult(t, id, plaintext)
+	}
+}
+
+func TestCycleWalkRejectedPlaintext(t *testing.T) {
+	t.Log("Testing CycleWalk encryption of a message the predicate rejects... ")
+	ff1, err := NewFF1("2B7E151628AED2A6ABF7158809CF4F3C", 10, 9, 9, 16)
+	assertNoError(t, err)
+	cycleWalk, err := NewCycleWalk(&ff1, validSSN, 100)
+	assertNoError(t, err)
+	_, err = cycleWalk.Encrypt("666123456", []byte{})
+	assertError(t, err)
+}
+
+func TestCycleWalkIterationLimit(t *testing.T) {
+	t.Log("Testing CycleWalk stops at the iteration limit... ")
+	ff1, err := NewFF1("2B7E151628AED2A6ABF7158809CF4F3C", 10, 2, 20, 16)
+	assertNoError(t, err)
+	cycleWalk, err := NewCycleWalk(&ff1, func(message string) bool {
+		return message == "0123456789"
+	}, 5)
+	assertNoError(t, err)
+	_, err = cycleWalk.Encrypt("0123456789", []byte{})
+	if err != ErrCycleWalkLimit {
+		t.Fatalf("Expected ErrCycleWalkLimit, got %v", err)
+	}
+}
+
+func TestNewCycleWalkWithoutPredicate(t *testing.T) {
+	t.Log("Testing NewCycleWalk without a predicate... ")
+	ff1, err := NewFF1("2B7E151628AED2A6ABF7158809CF4F3C", 10, 2, 20, 16)
+	assertNoError(t, err)
+	_, err = NewCycleWalk(&ff1, nil, 100)
+	assertError(t, err)
+}
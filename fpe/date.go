@@ -0,0 +1,227 @@
+package fpe
+
+import (
+	"errors"
+	"math/big"
+	"time"
+)
+
+// secondsPerDay converts between Unix times and day numbers.
+const secondsPerDay = 24 * 60 * 60
+
+// maxDateBits is the longest binary message a Date encrypts, which covers
+// windows of up to 2^32 days.
+const maxDateBits = 32
+
+// DefaultDateLayouts are the layouts a Date accepts when none are given, in
+// the order they are tried.
+var DefaultDateLayouts = []string{"2006-01-02", "01/02/2006", "2006/01/02", "20060102"}
+
+// The DateMode type chooses which parts of a date are kept when it is
+// encrypted by a Date.
+type DateMode int
+
+const (
+	// EncryptWholeDate encrypts a date to any date in the window.
+	EncryptWholeDate DateMode = iota
+	// KeepDateYear keeps the year of a date and encrypts the day of the year.
+	KeepDateYear
+	// KeepDateYearMonth keeps the year and month of a date and encrypts the
+	// day of the month.
+	KeepDateYearMonth
+)
+
+// The Date type allows for encryption and decryption of calendar dates, such
+// that a date encrypts to another valid date in the same window and layout.
+// The date is ranked by its number of days from the start of the part of the
+// window it can move in, and the rank is encrypted with FF1, cycle walking
+// until the result is inside that part. See the NewDate, (date *Date) Encrypt,
+// and (date *Date) Decrypt functions for more detail.
+type Date struct {
+	ff1 FF1
+	// minimum and maximum are the first and last days of the window.
+	minimum int64
+	maximum int64
+	mode    DateMode
+	layouts []string
+}
+
+// NewDate returns a new Date struct for encrypting and decrypting dates. It
+// will also return any errors encountered in creating an AES key.
+// The keyString argument should be the AES key string in hexadecimal, either
+// 16, 24, or 32 bytes to select AES-128, AES-192, or AES-256.
+// The minimum and maximum arguments should be the first and last dates of the
+// window dates are encrypted within. Only their year, month and day are used.
+// The mode argument should be EncryptWholeDate, KeepDateYear or
+// KeepDateYearMonth. The kept parts are added to the tweak, so dates with the
+// same day in different years or months do not encrypt alike.
+// The layouts argument should be the layouts of the dates, as used by the
+// time package, such as "2006-01-02" or "01/02/2006". A date is written in
+// the first layout that writes it exactly as given, and the encrypted date
+// is returned in the same layout. Layouts may overlap, such as "01/02/2006"
+// and "02/01/2006": dates are only encrypted to dates that read back in their
+// own layout, so 03/04/2001 written in the second layout can only encrypt to
+// a date whose day is above 12. A nil slice selects DefaultDateLayouts.
+// The maxTweakLength argument should be the maximum length of tweaks, in bytes.
+func NewDate(keyString string, minimum, maximum time.Time, mode DateMode, layouts []string, maxTweakLength int) (date Date, err error) {
+	if mode != EncryptWholeDate && mode != KeepDateYear && mode != KeepDateYearMonth {
+		return Date{}, errors.New("unknown date mode")
+	}
+	if layouts == nil {
+		layouts = DefaultDateLayouts
+	}
+	if len(layouts) == 0 {
+		return Date{}, errors.New("date needs at least one layout")
+	}
+
+	first, last := dayNumber(minimum), dayNumber(maximum)
+	if first >= last {
+		return Date{}, errors.New("date window must hold at least 2 days")
+	}
+	if last-first >= 1<<maxDateBits {
+		return Date{}, errors.New("date window was too long")
+	}
+
+	// The year and month added to the tweak take 3 bytes.
+	ff1, err := NewFF1(keyString, 2, minRangeBits, maxDateBits, maxTweakLength+3)
+	if err != nil {
+		return Date{}, err
+	}
+
+	return Date{
+		ff1:     ff1,
+		minimum: first,
+		maximum: last,
+		mode:    mode,
+		layouts: append([]string(nil), layouts...)}, nil
+}
+
+// MinimumDomainSize returns the number of days in the smallest part of the
+// window a date can move in. This is the whole window unless the year or
+// month is kept, when it is the shortest year or month inside the window, or
+// the part of one cut short by the ends of the window. Dates in a layout
+// that overlaps an earlier one can move to fewer days than this.
+func (date *Date) MinimumDomainSize() *big.Int {
+	smallest := date.maximum - date.minimum + 1
+	// The parts at the ends of the window may be cut short. Among the whole
+	// parts between them, any 48 in a row include a 365 day year or a 28 day
+	// February, which are the shortest.
+	for day, parts := date.minimum, 0; day <= date.maximum && parts < 48; parts++ {
+		first, last := date.bounds(time.Unix(day*secondsPerDay, 0).UTC())
+		smallest = minInt64(smallest, last-first+1)
+		day = last + 1
+	}
+	first, last := date.bounds(time.Unix(date.maximum*secondsPerDay, 0).UTC())
+	return big.NewInt(minInt64(smallest, last-first+1))
+}
+
+// Encrypt encrypts a date. It returns the encrypted date, in the same layout
+// and with the kept parts unchanged, along with any error encountered during
+// encryption.
+// The plaintext argument should be the date to encrypt.
+// The tweak argument should be the tweak to use in the encryption process.
+func (date *Date) Encrypt(plaintext string, tweak []byte) (message string, err error) {
+	return date.walk(plaintext, tweak, date.ff1.EncryptNumerals)
+}
+
+// Decrypt decrypts a date. It returns the decrypted date, in the same layout,
+// along with any error encountered during decryption.
+// The message argument should be the date to decrypt.
+// The tweak argument should be the tweak to use in the decryption process.
+func (date *Date) Decrypt(message string, tweak []byte) (plaintext string, err error) {
+	return date.walk(message, tweak, date.ff1.DecryptNumerals)
+}
+
+// Utility Functions for Date
+
+// walk parses a date and cycle walks its offset from the start of the days it
+// can move in with cipher.
+func (date *Date) walk(value string, tweak []byte, cipher func([]uint16, []byte) ([]uint16, error)) (string, error) {
+	parsed, layout, err := date.parse(value)
+	if err != nil {
+		return "", err
+	}
+	day := dayNumber(parsed)
+	if day < date.minimum || day > date.maximum {
+		return "", errors.New("date was outside the window")
+	}
+
+	first, last := date.bounds(parsed)
+	year, month, _ := parsed.Date()
+	switch date.mode {
+	case EncryptWholeDate:
+		year, month = 0, 0
+	case KeepDateYear:
+		month = 0
+	}
+	if first == last {
+		// Only this date keeps the kept parts inside the window.
+		return value, nil
+	}
+
+	size := big.NewInt(last - first + 1)
+	dateTweak := append(append([]byte(nil), tweak...), byte(year>>8), byte(year), byte(month))
+	offset := big.NewInt(day - first)
+	// Walk on past dates that would be read back in another layout or as
+	// another date, so the result always parses back to itself in layout.
+	// The date given does, so this stays a permutation.
+	for {
+		offset, err = walkOffset(offset, size, offsetBits(size), dateTweak, cipher)
+		if err != nil {
+			return "", err
+		}
+		result := first + offset.Int64()
+		written := time.Unix(result*secondsPerDay, 0).UTC().Format(layout)
+		reparsed, reparsedLayout, err := date.parse(written)
+		if err == nil && reparsedLayout == layout && dayNumber(reparsed) == result {
+			return written, nil
+		}
+	}
+}
+
+// bounds returns the first and last days of the window that a date can move
+// to, which keep its kept parts.
+func (date *Date) bounds(t time.Time) (first, last int64) {
+	first, last = date.minimum, date.maximum
+	year, month, _ := t.Date()
+	switch date.mode {
+	case KeepDateYear:
+		first = maxInt64(first, dayNumber(time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)))
+		last = minInt64(last, dayNumber(time.Date(year+1, time.January, 0, 0, 0, 0, 0, time.UTC)))
+	case KeepDateYearMonth:
+		first = maxInt64(first, dayNumber(time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)))
+		last = minInt64(last, dayNumber(time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC)))
+	}
+	return first, last
+}
+
+// parse returns a date and the first layout that writes it exactly as value.
+func (date *Date) parse(value string) (time.Time, string, error) {
+	for _, layout := range date.layouts {
+		parsed, err := time.Parse(layout, value)
+		if err == nil && parsed.Format(layout) == value {
+			return parsed, layout, nil
+		}
+	}
+	return time.Time{}, "", errors.New("date did not match any of the layouts")
+}
+
+// dayNumber returns the number of days from 1970-01-01 to the date of t.
+func dayNumber(t time.Time) int64 {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC).Unix() / secondsPerDay
+}
+
+func minInt64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,125 @@
+package fpe
+
+import (
+	"testing"
+	"time"
+)
+
+// newTestDate returns a Date over the window 1900-01-01 to 2030-12-31.
+func newTestDate(t *testing.T, mode DateMode, layouts []string) Date {
+	minimum := time.Date(1900, time.January, 1, 0, 0, 0, 0, time.UTC)
+	maximum := time.Date(2030, time.December, 31, 0, 0, 0, 0, time.UTC)
+	date, err := NewDate("2B7E151628AED2A6ABF7158809CF4F3C", minimum, maximum, mode, layouts, 16)
+	assertNoError(t, err)
+	return date
+}
+
+func TestDateEncrypt(t *testing.T) {
+	t.Log("Testing Date encryption of a whole date... ")
+	date := newTestDate(t, EncryptWholeDate, nil)
+	msg, err := date.Encrypt("1985-07-14", []byte{})
+	assertNoError(t, err)
+	assertExpectedResult(t, "2011-03-27", msg)
+	msg, err = date.Encrypt("2030-12-31", []byte{})
+	assertNoError(t, err)
+	assertExpectedResult(t, "2009-06-15", msg)
+}
+
+func TestDateDecrypt(t *testing.T) {
+	t.Log("Testing Date decryption of a whole date... ")
+	date := newTestDate(t, EncryptWholeDate, nil)
+	plaintext, err := date.Decrypt("2011-03-27", []byte{})
+	assertNoError(t, err)
+	assertExpectedResult(t, "1985-07-14", plaintext)
+}
+
+func TestDateKeepsLayout(t *testing.T) {
+	t.Log("Testing Date encryption returns the layout of the input... ")
+	date := newTestDate(t, EncryptWholeDate, nil)
+	msg, err := date.Encrypt("07/14/1985", []byte{})
+	assertNoError(t, err)
+	assertExpectedResult(t, "03/27/2011", msg)
+	msg, err = date.Encrypt("19850714", []byte{})
+	assertNoError(t, err)
+	assertExpectedResult(t, "20110327", msg)
+}
+
+func TestDateKeepYear(t *testing.T) {
+	t.Log("Testing Date encryption keeping the year... ")
+	date := newTestDate(t, KeepDateYear, nil)
+	msg, err := date.Encrypt("1985-07-14", []byte{})
+	assertNoError(t, err)
+	assertExpectedResult(t, "1985-10-04", msg)
+	tweak := []byte{0x39, 0x38, 0x37, 0x36, 0x35, 0x34, 0x33, 0x32, 0x31, 0x30}
+	msg, err = date.Encrypt("2000-02-29", tweak)
+	assertNoError(t, err)
+	assertExpectedResult(t, "2000-11-30", msg)
+	plaintext, err := date.Decrypt(msg, tweak)
+	assertNoError(t, err)
+	assertExpectedResult(t, "2000-02-29", plaintext)
+}
+
+func TestDateKeepYearMonth(t *testing.T) {
+	t.Log("Testing Date encryption keeping the year and month... ")
+	date := newTestDate(t, KeepDateYearMonth, nil)
+	msg, err := date.Encrypt("1985-07-14", []byte{})
+	assertNoError(t, err)
+	assertExpectedResult(t, "1985-07-06", msg)
+	tweak := []byte{0x39, 0x38, 0x37, 0x36, 0x35, 0x34, 0x33, 0x32, 0x31, 0x30}
+	msg, err = date.Encrypt("2000-02-29", tweak)
+	assertNoError(t, err)
+	assertExpectedResult(t, "2000-02-10", msg)
+}
+
+func TestDateIsPermutation(t *testing.T) {
+	t.Log("Testing Date maps every day of a month to a different day... ")
+	date := newTestDate(t, KeepDateYearMonth, []string{"2006-01-02"})
+	seen := make(map[string]bool)
+	for day := time.Date(1996, time.February, 1, 0, 0, 0, 0, time.UTC); day.Month() == time.February; day = day.AddDate(0, 0, 1) {
+		msg, err := date.Encrypt(day.Format("2006-01-02"), []byte{})
+		assertNoError(t, err)
+		if msg[:8] != "1996-02-" || seen[msg] {
+			t.Fatalf("Expected a new day of February 1996, got %s", msg)
+		}
+		seen[msg] = true
+		plaintext, err := date.Decrypt(msg, []byte{})
+		assertNoError(t, err)
+		assertExpectedResult(t, day.Format("2006-01-02"), plaintext)
+	}
+}
+
+func TestDateOverlappingLayouts(t *testing.T) {
+	t.Log("Testing Date round trips dates in layouts that overlap... ")
+	date := newTestDate(t, KeepDateYear, []string{"01/02/2006", "02/01/2006"})
+	for day := time.Date(2001, time.January, 1, 0, 0, 0, 0, time.UTC); day.Year() == 2001; day = day.AddDate(0, 0, 1) {
+		layout := "01/02/2006"
+		if day.Day() > 12 {
+			layout = "02/01/2006"
+		}
+		value := day.Format(layout)
+		msg, err := date.Encrypt(value, []byte{})
+		assertNoError(t, err)
+		if _, err := time.Parse("01/02/2006", msg); (err != nil) != (layout == "02/01/2006") {
+			t.Errorf("Expected %s to be read back in the layout of %s", msg, value)
+		}
+		plaintext, err := date.Decrypt(msg, []byte{})
+		assertNoError(t, err)
+		assertExpectedResult(t, value, plaintext)
+	}
+}
+
+func TestDateOutsideWindow(t *testing.T) {
+	t.Log("Testing Date encryption of a date outside the window... ")
+	date := newTestDate(t, EncryptWholeDate, nil)
+	_, err := date.Encrypt("1899-12-31", []byte{})
+	assertError(t, err)
+}
+
+func TestDateInvalidDate(t *testing.T) {
+	t.Log("Testing Date encryption of a date that is not on the calendar... ")
+	date := newTestDate(t, EncryptWholeDate, nil)
+	_, err := date.Encrypt("1985-02-29", []byte{})
+	assertError(t, err)
+	_, err = date.Encrypt("7/14/1985", []byte{})
+	assertError(t, err)
+}
@@ -0,0 +1,95 @@
+package fpe
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+)
+
+// The DomainSizer interface is implemented by the types of this package that
+// can report the smallest domain they encrypt a message within, which is what
+// a DomainPolicy checks.
+type DomainSizer interface {
+	// MinimumDomainSize returns the number of messages in the smallest domain
+	// a message is encrypted within, or nil if it cannot be told.
+	MinimumDomainSize() *big.Int
+}
+
+// The DomainPolicy type sets the smallest domain a message may be encrypted
+// within. Small domains can be enumerated and weaken the Feistel based modes,
+// so NIST SP 800-38G sets a minimum. See Rev1DomainPolicy, LegacyDomainPolicy
+// and NewDomainPolicy.
+type DomainPolicy struct {
+	name    string
+	minimum *big.Int
+}
+
+var (
+	// Rev1DomainPolicy requires domains of at least 1000000 messages, the
+	// minimum of NIST SP 800-38G Rev.1.
+	Rev1DomainPolicy = DomainPolicy{name: "rev1", minimum: big.NewInt(1000000)}
+	// LegacyDomainPolicy requires domains of at least 100 messages, the
+	// minimum of the original NIST SP 800-38G that NewFF1 and NewFF3 check.
+	LegacyDomainPolicy = DomainPolicy{name: "legacy", minimum: big.NewInt(100)}
+)
+
+// NewDomainPolicy returns a new DomainPolicy requiring domains of at least
+// minimum messages, which must be at least 1.
+func NewDomainPolicy(minimum *big.Int) (policy DomainPolicy, err error) {
+	if minimum.Sign() <= 0 {
+		return DomainPolicy{}, errors.New("domain policy minimum must be at least 1")
+	}
+	return DomainPolicy{name: "custom", minimum: new(big.Int).Set(minimum)}, nil
+}
+
+// ParseDomainPolicy returns the DomainPolicy named by a string, which is
+// "rev1", "legacy", or a decimal minimum for a custom policy.
+func ParseDomainPolicy(name string) (policy DomainPolicy, err error) {
+	switch strings.ToLower(name) {
+	case "rev1":
+		return Rev1DomainPolicy, nil
+	case "legacy":
+		return LegacyDomainPolicy, nil
+	}
+	minimum, ok := new(big.Int).SetString(name, 10)
+	if !ok {
+		return DomainPolicy{}, errors.New("domain policy must be rev1, legacy or a minimum domain size")
+	}
+	return NewDomainPolicy(minimum)
+}
+
+// String returns the name of the policy, which is "rev1", "legacy" or
+// "custom".
+func (policy DomainPolicy) String() string {
+	return policy.name
+}
+
+// Minimum returns the number of messages the policy requires of a domain.
+func (policy DomainPolicy) Minimum() *big.Int {
+	return new(big.Int).Set(policy.minimum)
+}
+
+// Check returns an error if algorithm encrypts some message within a domain
+// smaller than the policy allows, or if its domain size cannot be told.
+func (policy DomainPolicy) Check(algorithm interface{}) error {
+	sizer, ok := algorithm.(DomainSizer)
+	if !ok {
+		return errors.New("domain size of the algorithm is unknown")
+	}
+	size := sizer.MinimumDomainSize()
+	if size == nil {
+		return errors.New("domain size of the algorithm is unknown")
+	}
+	if size.Cmp(policy.minimum) < 0 {
+		return errors.New("domain of " + size.String() + " messages is below the " +
+			policy.name + " policy minimum of " + policy.minimum.String())
+	}
+	return nil
+}
+
+// Utility Functions for DomainPolicy
+
+// powInt returns radix^exponent.
+func powInt(radix, exponent int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(int64(radix)), big.NewInt(int64(exponent)), nil)
+}
@@ -0,0 +1,116 @@
+package fpe
+
+import (
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestDomainPolicyRev1(t *testing.T) {
+	t.Log("Testing the Rev.1 domain policy accepts radix^minlen of one million... ")
+	ff1, err := NewFF1("2B7E151628AED2A6ABF7158809CF4F3C", 10, 6, 10, 16)
+	assertNoError(t, err)
+	assertNoError(t, Rev1DomainPolicy.Check(&ff1))
+	ff1, err = NewFF1("2B7E151628AED2A6ABF7158809CF4F3C", 10, 5, 10, 16)
+	assertNoError(t, err)
+	assertError(t, Rev1DomainPolicy.Check(&ff1))
+}
+
+func TestDomainPolicyLegacy(t *testing.T) {
+	t.Log("Testing the legacy domain policy accepts radix^minlen of 100... ")
+	ff3, err := NewFF31("2B7E151628AED2A6ABF7158809CF4F3C", 10, 2, 10)
+	assertNoError(t, err)
+	assertNoError(t, LegacyDomainPolicy.Check(&ff3))
+	assertError(t, Rev1DomainPolicy.Check(&ff3))
+	smallDomain, _, err := NewSmallDomain("2B7E151628AED2A6ABF7158809CF4F3C", 10, 1, 2, 16)
+	assertNoError(t, err)
+	assertError(t, LegacyDomainPolicy.Check(&smallDomain))
+}
+
+func TestDomainPolicyCustom(t *testing.T) {
+	t.Log("Testing a custom domain policy... ")
+	policy, err := ParseDomainPolicy("10")
+	assertNoError(t, err)
+	assertExpectedResult(t, "custom", policy.String())
+	assertExpectedResult(t, "10", policy.Minimum().String())
+	smallDomain, _, err := NewSmallDomain("2B7E151628AED2A6ABF7158809CF4F3C", 10, 1, 2, 16)
+	assertNoError(t, err)
+	assertNoError(t, policy.Check(&smallDomain))
+}
+
+func TestParseDomainPolicy(t *testing.T) {
+	t.Log("Testing parsing domain policy names... ")
+	policy, err := ParseDomainPolicy("Rev1")
+	assertNoError(t, err)
+	assertExpectedResult(t, "1000000", policy.Minimum().String())
+	policy, err = ParseDomainPolicy("legacy")
+	assertNoError(t, err)
+	assertExpectedResult(t, "100", policy.Minimum().String())
+	_, err = ParseDomainPolicy("strict")
+	assertError(t, err)
+	_, err = ParseDomainPolicy("0")
+	assertError(t, err)
+}
+
+func TestDomainPolicyUnknownSize(t *testing.T) {
+	t.Log("Testing a domain policy refuses algorithms of unknown domain size... ")
+	ff1, err := NewFF1("2B7E151628AED2A6ABF7158809CF4F3C", 10, 9, 9, 16)
+	assertNoError(t, err)
+	cycleWalk, err := NewCycleWalk(&ff1, func(string) bool { return true }, 1)
+	assertNoError(t, err)
+	assertError(t, LegacyDomainPolicy.Check(&cycleWalk))
+	template, err := NewTemplate(&cycleWalk, "DDD-DD-DDDD")
+	assertNoError(t, err)
+	assertError(t, LegacyDomainPolicy.Check(&template))
+}
+
+func TestMinimumDomainSizes(t *testing.T) {
+	t.Log("Testing the minimum domain sizes of the formats... ")
+	ff1, err := NewFF1("2B7E151628AED2A6ABF7158809CF4F3C", 10, 9, 9, 16)
+	assertNoError(t, err)
+	template, err := NewTemplate(&ff1, "DDD-DD-DDDD")
+	assertNoError(t, err)
+	assertExpectedResult(t, "1000000000", template.MinimumDomainSize().String())
+
+	card, err := NewCardNumber("2B7E151628AED2A6ABF7158809CF4F3C", 16)
+	assertNoError(t, err)
+	assertExpectedResult(t, "10", card.MinimumDomainSize().String())
+	card, err = NewCardNumberWithLengths("2B7E151628AED2A6ABF7158809CF4F3C", 16, 16, 16)
+	assertNoError(t, err)
+	assertExpectedResult(t, "100000", card.MinimumDomainSize().String())
+
+	integerRange, err := NewIntegerRangeInt64("2B7E151628AED2A6ABF7158809CF4F3C", 1, 999, 16)
+	assertNoError(t, err)
+	assertExpectedResult(t, "999", integerRange.MinimumDomainSize().String())
+
+	email, err := NewEmail("2B7E151628AED2A6ABF7158809CF4F3C", KeepEmailDomain, 16)
+	assertNoError(t, err)
+	assertExpectedResult(t, "6561", email.MinimumDomainSize().String())
+	email, err = NewEmail("2B7E151628AED2A6ABF7158809CF4F3C", EncryptEmailDomain, 16)
+	assertNoError(t, err)
+	assertExpectedResult(t, "1296", email.MinimumDomainSize().String())
+}
+
+func TestDateMinimumDomainSize(t *testing.T) {
+	t.Log("Testing the minimum domain sizes of dates... ")
+	from := time.Date(1999, time.July, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2003, time.December, 31, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		mode     DateMode
+		expected int64
+	}{
+		{EncryptWholeDate, 1645},
+		{KeepDateYear, 184},
+		{KeepDateYearMonth, 28},
+	}
+	for _, c := range cases {
+		date, err := NewDate("2B7E151628AED2A6ABF7158809CF4F3C", from, to, c.mode, nil, 16)
+		assertNoError(t, err)
+		assertExpectedResult(t, big.NewInt(c.expected).String(), date.MinimumDomainSize().String())
+	}
+
+	date, err := NewDate("2B7E151628AED2A6ABF7158809CF4F3C", from,
+		time.Date(2000, time.January, 2, 0, 0, 0, 0, time.UTC), KeepDateYear, nil, 16)
+	assertNoError(t, err)
+	assertExpectedResult(t, "2", date.MinimumDomainSize().String())
+}
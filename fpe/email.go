@@ -0,0 +1,208 @@
+package fpe
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+	"unicode"
+)
+
+const (
+	// emailLocalCharacters are the characters of an unquoted local part other
+	// than the dot, following the dot-atom form of RFC 5322.
+	emailLocalCharacters = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ!#$%&'*+-/=?^_`{|}~"
+	// emailDomainCharacters are the characters of a domain label, which are
+	// letters, digits and hyphens. Domains ignore case, so upper case letters
+	// are accepted too and returned in lower case.
+	emailDomainCharacters = "0123456789abcdefghijklmnopqrstuvwxyz-"
+	// maxEmailLocalLength and maxEmailDomainLength are the longest local part
+	// and domain allowed by RFC 5321, counting their dots.
+	maxEmailLocalLength  = 64
+	maxEmailDomainLength = 253
+	// maxEmailIterations caps the cycle walk that keeps hyphens away from the
+	// ends of encrypted domain labels.
+	maxEmailIterations = 1000
+)
+
+// The EmailDomainMode type chooses what happens to the domain of an email
+// address when it is encrypted by an Email.
+type EmailDomainMode int
+
+const (
+	// KeepEmailDomain keeps the whole domain, so only the local part is
+	// encrypted.
+	KeepEmailDomain EmailDomainMode = iota
+	// EncryptEmailDomain encrypts the labels of the domain other than the top
+	// level domain, which is kept.
+	EncryptEmailDomain
+)
+
+// The Email type allows for encryption and decryption of email addresses,
+// such that an address encrypts to another syntactically valid address. The
+// local part is encrypted with FF1, keeping its dots in place, and the domain
+// is either kept or encrypted apart from its top level domain. See the
+// NewEmail, (email *Email) Encrypt, and (email *Email) Decrypt functions for
+// more detail.
+type Email struct {
+	localPart FF1
+	domain    FF1
+	mode      EmailDomainMode
+}
+
+// NewEmail returns a new Email struct for encrypting and decrypting email
+// addresses. It will also return any errors encountered in creating an AES
+// key.
+// The keyString argument should be the AES key string in hexadecimal, either
+// 16, 24, or 32 bytes to select AES-128, AES-192, or AES-256.
+// The mode argument should be KeepEmailDomain or EncryptEmailDomain.
+// The maxTweakLength argument should be the maximum length of tweaks, in bytes.
+func NewEmail(keyString string, mode EmailDomainMode, maxTweakLength int) (email Email, err error) {
+	if mode != KeepEmailDomain && mode != EncryptEmailDomain {
+		return Email{}, errors.New("unknown email domain mode")
+	}
+
+	localAlphabet, _ := NewAlphabet(emailLocalCharacters)
+	localPart, err := NewFF1WithAlphabet(keyString, localAlphabet, 2, maxEmailLocalLength, maxTweakLength)
+	if err != nil {
+		return Email{}, err
+	}
+
+	domainAlphabet, _ := NewAlphabet(emailDomainCharacters)
+	for i, r := range domainAlphabet.characters {
+		domainAlphabet.numerals[unicode.ToUpper(r)] = uint16(i)
+	}
+	domain, err := NewFF1WithAlphabet(keyString, domainAlphabet, 2, maxEmailDomainLength, maxTweakLength)
+	if err != nil {
+		return Email{}, err
+	}
+
+	return Email{localPart: localPart, domain: domain, mode: mode}, nil
+}
+
+// MinimumDomainSize returns the number of local parts of 2 characters, or
+// when the domain is encrypted, the fewer number of 2 character labels that
+// do not start or end with a hyphen.
+func (email *Email) MinimumDomainSize() *big.Int {
+	if email.mode == EncryptEmailDomain {
+		return powInt(email.domain.radix-1, 2)
+	}
+	return email.localPart.MinimumDomainSize()
+}
+
+// Encrypt encrypts an email address. It returns the encrypted address, along
+// with any error encountered during encryption.
+// The plaintext argument should be an address with an unquoted local part of
+// at least 2 characters other than dots. When the domain is encrypted, the
+// labels before the top level domain must have at least 2 characters between
+// them.
+// The tweak argument should be the tweak to use in the encryption process.
+func (email *Email) Encrypt(plaintext string, tweak []byte) (message string, err error) {
+	return email.apply(plaintext, tweak, email.localPart.Encrypt, email.domain.Encrypt)
+}
+
+// Decrypt decrypts an email address. It returns the decrypted address, along
+// with any error encountered during decryption. When the domain is encrypted,
+// it is returned in lower case.
+// The message argument should be the address to decrypt.
+// The tweak argument should be the tweak to use in the decryption process.
+func (email *Email) Decrypt(message string, tweak []byte) (plaintext string, err error) {
+	return email.apply(message, tweak, email.localPart.Decrypt, email.domain.Decrypt)
+}
+
+// Utility Functions for Email
+
+// apply splits an address into its local part and domain and applies the
+// local part and domain ciphers to them.
+func (email *Email) apply(address string, tweak []byte, localCipher, domainCipher func(string, []byte) (string, error)) (string, error) {
+	if strings.Count(address, "@") != 1 {
+		return "", errors.New("email address must contain exactly one @")
+	}
+	at := strings.Index(address, "@")
+	localPart, domain := address[:at], address[at+1:]
+
+	localPart, err := email.applyLocalPart(localPart, tweak, localCipher)
+	if err != nil {
+		return "", err
+	}
+	if email.mode == EncryptEmailDomain {
+		domain, err = email.applyDomain(domain, tweak, domainCipher)
+		if err != nil {
+			return "", err
+		}
+	} else if domain == "" {
+		return "", errors.New("email address had an empty domain")
+	}
+	return localPart + "@" + domain, nil
+}
+
+// applyLocalPart applies cipher to the characters of a local part other than
+// its dots, which are kept in place.
+func (email *Email) applyLocalPart(localPart string, tweak []byte, cipher func(string, []byte) (string, error)) (string, error) {
+	if len(localPart) > maxEmailLocalLength {
+		return "", errors.New("email local part was longer than 64 characters")
+	}
+	if strings.HasPrefix(localPart, ".") || strings.HasSuffix(localPart, ".") || strings.Contains(localPart, "..") {
+		return "", errors.New("email local part had a misplaced dot")
+	}
+
+	characters, err := cipher(strings.Replace(localPart, ".", "", -1), tweak)
+	if err != nil {
+		return "", err
+	}
+	return restoreSeparators(localPart, characters, '.'), nil
+}
+
+// applyDomain applies cipher to the labels of a domain other than the top
+// level domain, cycle walking until no label starts or ends with a hyphen.
+// The dots between labels are kept in place.
+func (email *Email) applyDomain(domain string, tweak []byte, cipher func(string, []byte) (string, error)) (string, error) {
+	if len(domain) > maxEmailDomainLength {
+		return "", errors.New("email domain was longer than 253 characters")
+	}
+	lastDot := strings.LastIndex(domain, ".")
+	if lastDot < 0 {
+		return "", errors.New("email domain must have a top level domain to keep")
+	}
+	labels, topLevelDomain := domain[:lastDot], domain[lastDot:]
+	if !validLabels(labels) || !validLabels(topLevelDomain[1:]) {
+		return "", errors.New("email domain was not a valid host name")
+	}
+
+	characters, err := walkUntil(strings.Replace(labels, ".", "", -1), tweak, cipher, func(characters string) bool {
+		return validLabels(restoreSeparators(labels, characters, '.'))
+	}, maxEmailIterations)
+	if err != nil {
+		return "", err
+	}
+	return restoreSeparators(labels, strings.ToLower(characters), '.') + topLevelDomain, nil
+}
+
+// validLabels reports whether every dot separated label is non-empty and does
+// not start or end with a hyphen. The characters of the labels are checked by
+// the domain alphabet.
+func validLabels(labels string) bool {
+	for _, label := range strings.Split(labels, ".") {
+		if label == "" || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// restoreSeparators returns the characters with a separator inserted at each
+// position where template has one. The characters must be as many as the
+// runes of template that are not separators.
+func restoreSeparators(template, characters string, separator rune) string {
+	runes := []rune(characters)
+	var restored strings.Builder
+	next := 0
+	for _, r := range template {
+		if r == separator {
+			restored.WriteRune(separator)
+			continue
+		}
+		restored.WriteRune(runes[next])
+		next++
+	}
+	return restored.String()
+}
@@ -0,0 +1,96 @@
+package fpe
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEmailEncryptKeepDomain(t *testing.T) {
+	t.Log("Testing Email encryption keeping the domain... ")
+	email, err := NewEmail("2B7E151628AED2A6ABF7158809CF4F3C", KeepEmailDomain, 16)
+	assertNoError(t, err)
+	msg, err := email.Encrypt("jane.doe@unitehere.org", []byte{})
+	assertNoError(t, err)
+	assertExpectedResult(t, "1^RZ.2D6@unitehere.org", msg)
+}
+
+func TestEmailDecryptKeepDomain(t *testing.T) {
+	t.Log("Testing Email decryption keeping the domain... ")
+	email, err := NewEmail("2B7E151628AED2A6ABF7158809CF4F3C", KeepEmailDomain, 16)
+	assertNoError(t, err)
+	plaintext, err := email.Decrypt("1^RZ.2D6@unitehere.org", []byte{})
+	assertNoError(t, err)
+	assertExpectedResult(t, "jane.doe@unitehere.org", plaintext)
+}
+
+func TestEmailEncryptDomain(t *testing.T) {
+	t.Log("Testing Email encryption of the domain... ")
+	email, err := NewEmail("2B7E151628AED2A6ABF7158809CF4F3C", EncryptEmailDomain, 16)
+	assertNoError(t, err)
+	msg, err := email.Encrypt("jane.doe@unitehere.org", []byte{})
+	assertNoError(t, err)
+	assertExpectedResult(t, "1^RZ.2D6@5yv-lwgvc.org", msg)
+	plaintext, err := email.Decrypt(msg, []byte{})
+	assertNoError(t, err)
+	assertExpectedResult(t, "jane.doe@unitehere.org", plaintext)
+}
+
+func TestEmailEncryptDomainWithTweak(t *testing.T) {
+	t.Log("Testing Email round trip of a subdomain with a tweak... ")
+	email, err := NewEmail("2B7E151628AED2A6ABF7158809CF4F3C", EncryptEmailDomain, 16)
+	assertNoError(t, err)
+	tweak := []byte{0x39, 0x38, 0x37, 0x36, 0x35, 0x34, 0x33, 0x32, 0x31, 0x30}
+	msg, err := email.Encrypt("Member+Local.42@Mail.UniteHere.org", tweak)
+	assertNoError(t, err)
+	assertExpectedResult(t, "/Svc!nfdt1?x.jb@s-zv.9ocyg5ykf.org", msg)
+	plaintext, err := email.Decrypt(msg, tweak)
+	assertNoError(t, err)
+	assertExpectedResult(t, "Member+Local.42@mail.unitehere.org", plaintext)
+}
+
+func TestEmailEncryptDomainCycleWalk(t *testing.T) {
+	t.Log("Testing Email encryption of a domain whose labels need a cycle walk... ")
+	email, err := NewEmail("2B7E151628AED2A6ABF7158809CF4F3C", EncryptEmailDomain, 16)
+	assertNoError(t, err)
+	msg, err := email.Encrypt("jo@d.e.com", []byte{})
+	assertNoError(t, err)
+	assertExpectedResult(t, "K{@r.j.com", msg)
+	plaintext, err := email.Decrypt(msg, []byte{})
+	assertNoError(t, err)
+	assertExpectedResult(t, "jo@d.e.com", plaintext)
+}
+
+func TestEmailWithMisplacedDot(t *testing.T) {
+	t.Log("Testing Email encryption of a local part with consecutive dots... ")
+	email, err := NewEmail("2B7E151628AED2A6ABF7158809CF4F3C", KeepEmailDomain, 16)
+	assertNoError(t, err)
+	_, err = email.Encrypt("jane..doe@unitehere.org", []byte{})
+	assertError(t, err)
+}
+
+func TestEmailWithLongLocalPart(t *testing.T) {
+	t.Log("Testing Email encryption of a local part longer than 64 characters with its dots... ")
+	email, err := NewEmail("2B7E151628AED2A6ABF7158809CF4F3C", KeepEmailDomain, 16)
+	assertNoError(t, err)
+	localPart := strings.Repeat("a.", 32) + "a"
+	_, err = email.Encrypt(localPart+"@unitehere.org", []byte{})
+	assertError(t, err)
+	_, err = email.Encrypt(localPart[2:]+"@unitehere.org", []byte{})
+	assertNoError(t, err)
+}
+
+func TestEmailWithoutAt(t *testing.T) {
+	t.Log("Testing Email encryption of a string without an @... ")
+	email, err := NewEmail("2B7E151628AED2A6ABF7158809CF4F3C", KeepEmailDomain, 16)
+	assertNoError(t, err)
+	_, err = email.Encrypt("jane.doe.unitehere.org", []byte{})
+	assertError(t, err)
+}
+
+func TestEmailEncryptDomainWithoutTopLevelDomain(t *testing.T) {
+	t.Log("Testing Email encryption of a domain without a top level domain... ")
+	email, err := NewEmail("2B7E151628AED2A6ABF7158809CF4F3C", EncryptEmailDomain, 16)
+	assertNoError(t, err)
+	_, err = email.Encrypt("jane@localhost", []byte{})
+	assertError(t, err)
+}
@@ -7,27 +7,85 @@ import (
 	"encoding/hex"
 	"errors"
 	"math/big"
-	"strconv"
+	"math/bits"
+	"sync"
 )
 
 // The FF1 type allows for encryption and decryption of messages using the FF1
 // mode of format preserving encryption. See the NewFF1, (ff1 *FF1) Encrypt,
 // and (ff1 *FF1) Decrypt functions for more detail.
 type FF1 struct {
-	cipher           cipher.Block
+	cipher           *destroyableBlock
+	alphabet         *Alphabet
 	radix            int
 	minMessageLength int
 	maxMessageLength int
 	maxTweakLength   int
+	constantsCache   *ff1ConstantsCache
+}
 
+// The ff1Constants type holds the values that depend on the length of the
+// message and tweak being encrypted or decrypted. They are computed once for
+// each pair of lengths and cached, and each call works on its own copy, so a
+// single FF1 can be shared between goroutines while the cache zeroes the
+// constants it evicts.
+type ff1Constants struct {
 	messageLength       int
 	firstHalfLength     int
 	secondHalfLength    int
-	firstHalf           string
-	secondHalf          string
 	messageByteLength   int
 	cipheredBlockLength int
 	fixedBlock          [16]byte
+	// fixedBlockMAC is the CBC-MAC state after the fixed block P, from which
+	// the pseudorandom function of every round continues.
+	fixedBlockMAC [16]byte
+	// variableBlockLength is the length of the variable block Q.
+	variableBlockLength int
+	// radixPowFirstHalf and radixPowSecondHalf are radix^u and radix^v.
+	radixPowFirstHalf  *big.Int
+	radixPowSecondHalf *big.Int
+	// fastPath is whether radix^u and radix^v are below 2^64 and Q fits in an
+	// ff1Scratch, so feistelUint64 can be used. radixPowFirstHalf64 and
+	// radixPowSecondHalf64 are then radix^u and radix^v.
+	fastPath             bool
+	radixPowFirstHalf64  uint64
+	radixPowSecondHalf64 uint64
+	// cipher is the block cipher of the FF1, acquired for each call and not
+	// cached.
+	cipher cipher.Block
+}
+
+// maxFastVariableBlockLength is the longest variable block Q that
+// feistelUint64 handles, which allows tweaks of up to 55 bytes.
+const maxFastVariableBlockLength = 64
+
+// The ff1Scratch type holds the blocks feistelUint64 passes to the block
+// cipher.
+type ff1Scratch struct {
+	block         [16]byte
+	variableBlock [maxFastVariableBlockLength]byte
+}
+
+// ff1ScratchPool reuses ff1Scratch values between calls to feistelUint64.
+var ff1ScratchPool = sync.Pool{New: func() interface{} { return new(ff1Scratch) }}
+
+// The ff1ConstantsKey type is the message and tweak lengths that a set of
+// ff1Constants is for.
+type ff1ConstantsKey struct {
+	messageLength int
+	tweakLength   int
+}
+
+// maxFF1Constants is the most sets of constants an FF1 caches before it starts
+// over, which bounds its memory when message and tweak lengths vary.
+const maxFF1Constants = 256
+
+// The ff1ConstantsCache type holds the constants computed by an FF1, keyed by
+// message and tweak length. It is shared by copies of the FF1, and holds at
+// most maxFF1Constants entries.
+type ff1ConstantsCache struct {
+	mutex     sync.RWMutex
+	constants map[ff1ConstantsKey]*ff1Constants
 }
 
 // NewFF1 returns a new FF1 struct for encrypting and decrypting messages using
@@ -36,7 +94,11 @@ type FF1 struct {
 // The keyString argument should be the AES key string in hexadecimal, either
 // 16, 24, or 32 bytes to select AES-128, AES-192, or AES-256.
 // The radix argument should be the number of characters in the alphabet that
-// will be used. It can be any integer from 2 to 36 inclusive.
+// will be used. It can be any integer from 2 to 65536 inclusive. Messages are
+// written with the first radix characters of 0-9a-zA-Z, ignoring the case of
+// letters when the radix is 36 or less. Encrypt and Decrypt can only be used
+// with a radix above 62 if the FF1 was made with NewFF1WithAlphabet; otherwise
+// use EncryptNumerals and DecryptNumerals.
 // The minMessageLength and maxMessageLength arguments should be the minimum
 // and maximum message lengths that will be allowed.
 // The maxTweakLength argument should be the maximum length of tweaks, in bytes.
@@ -45,11 +107,38 @@ func NewFF1(keyString string, radix, minMessageLength, maxMessageLength, maxTwea
 	if err != nil {
 		return FF1{}, err
 	}
+	defer ZeroBytes(key)
+
+	return NewFF1WithKey(key, radix, minMessageLength, maxMessageLength, maxTweakLength)
+}
+
+// NewFF1WithKey returns a new FF1 struct like NewFF1, with the AES key given
+// as 16, 24, or 32 bytes instead of a hexadecimal string. The FF1 keeps its
+// own copy of the key until Destroy zeroes it, so the caller can zero key once
+// NewFF1WithKey returns.
+func NewFF1WithKey(key []byte, radix, minMessageLength, maxMessageLength, maxTweakLength int) (ff1 FF1, err error) {
 	cph, err := aes.NewCipher(key)
 	if err != nil {
 		return FF1{}, err
 	}
 
+	ff1, err = NewFF1WithCipher(cph, radix, minMessageLength, maxMessageLength, maxTweakLength)
+	if err != nil {
+		return FF1{}, err
+	}
+	ff1.cipher = newDestroyableBlock(cph, key)
+	return ff1, nil
+}
+
+// NewFF1WithCipher returns a new FF1 struct like NewFF1, using a ready made
+// block cipher instead of creating one from a key. This allows keys held by
+// an HSM or another implementation of AES to be used directly. The block
+// cipher must have a 16 byte block size and be safe for concurrent use.
+func NewFF1WithCipher(block cipher.Block, radix, minMessageLength, maxMessageLength, maxTweakLength int) (ff1 FF1, err error) {
+	if block == nil || block.BlockSize() != aes.BlockSize {
+		return FF1{}, errors.New("cipher must have a 16 byte block size")
+	}
+
 	if radix < 2 || radix > 65536 {
 		return FF1{}, errors.New("radix must be in [2..2^16]")
 	}
@@ -61,220 +150,429 @@ func NewFF1(keyString string, radix, minMessageLength, maxMessageLength, maxTwea
 	bigRadix := big.NewInt(int64(radix))
 	bigMinLen := big.NewInt(int64(minMessageLength))
 	if bigRadix.Exp(bigRadix, bigMinLen, nil).Cmp(big.NewInt(int64(100))) < 0 {
-		return FF1{}, errors.New("radix^minlen >= 100")
+		return FF1{}, errors.New("radix^minlen >= 100: use NewSmallDomain for smaller domains")
 	}
 
 	return FF1{
-		cipher:           cph,
+		cipher:           newDestroyableBlock(block, nil),
+		alphabet:         defaultAlphabet(radix),
 		radix:            radix,
 		minMessageLength: minMessageLength,
 		maxMessageLength: maxMessageLength,
-		maxTweakLength:   maxTweakLength}, nil
+		maxTweakLength:   maxTweakLength,
+		constantsCache:   &ff1ConstantsCache{constants: make(map[ff1ConstantsKey]*ff1Constants)}}, nil
+}
+
+// NewFF1WithAlphabet returns a new FF1 struct like NewFF1, except that messages
+// are written using the characters of the given alphabet instead of the first
+// radix characters of 0-9a-zA-Z. The radix is the number of characters in the
+// alphabet.
+func NewFF1WithAlphabet(keyString string, alphabet Alphabet, minMessageLength, maxMessageLength, maxTweakLength int) (ff1 FF1, err error) {
+	ff1, err = NewFF1(keyString, alphabet.Radix(), minMessageLength, maxMessageLength, maxTweakLength)
+	if err != nil {
+		return FF1{}, err
+	}
+
+	ff1.alphabet = &alphabet
+	return ff1, nil
+}
+
+// MinimumDomainSize returns the number of messages of the minimum length,
+// which is radix^minlen.
+func (ff1 *FF1) MinimumDomainSize() *big.Int {
+	return powInt(ff1.radix, ff1.minMessageLength)
+}
+
+// Destroy zeroes the copy of the key held by ff1 and the key dependent
+// constants it has cached, and drops its block cipher, after which encryption
+// and decryption with ff1 or any copy of it return ErrDestroyed. It waits for
+// calls already under way on other goroutines to finish first. The AES key
+// schedule inside the crypto/aes block cipher cannot be reached to be zeroed,
+// so it is only dropped, and an FF1 made with NewFF1WithCipher holds no key.
+func (ff1 *FF1) Destroy() {
+	ff1.cipher.destroy(func() {
+		if ff1.constantsCache == nil {
+			return
+		}
+		ff1.constantsCache.mutex.Lock()
+		ff1.constantsCache.wipe()
+		ff1.constantsCache.mutex.Unlock()
+	})
 }
 
 // Encrypt uses the AES key string and arguments used to construct ff1 to
 // encrypt a message. It returns the encrypted message, along with any error
-// encountered during encryption.
+// encountered during encryption. It is safe to call Encrypt and Decrypt
+// concurrently on the same FF1.
 // The plaintext argument should be the message to encrypt.
 // The tweak argument should be the tweak to use in the encryption process.
 func (ff1 *FF1) Encrypt(plaintext string, tweak []byte) (message string, err error) {
-	err = ff1.prepareConstants(plaintext, tweak)
+	if ff1.alphabet == nil {
+		return message, errors.New("radix has no default alphabet: use EncryptNumerals or NewFF1WithAlphabet")
+	}
+	numerals, err := ff1.alphabet.toNumerals(plaintext)
 	if err != nil {
 		return message, err
 	}
+	numerals, err = ff1.EncryptNumerals(numerals, tweak)
+	if err != nil {
+		return message, err
+	}
+	return ff1.alphabet.fromNumerals(numerals), nil
+}
 
-	radixBig := big.NewInt(int64(ff1.radix))
+// Decrypt uses the AES key string and arguments used to construct ff1 to
+// decrypt a message. It returns the decrypted message, along with any error
+// encountered during decryption. It is safe to call Encrypt and Decrypt
+// concurrently on the same FF1.
+// The plaintext argument should be the message to decrypt.
+// The tweak argument should be the tweak to use in the decryption process.
+func (ff1 *FF1) Decrypt(message string, tweak []byte) (plaintext string, err error) {
+	if ff1.alphabet == nil {
+		return plaintext, errors.New("radix has no default alphabet: use DecryptNumerals or NewFF1WithAlphabet")
+	}
+	numerals, err := ff1.alphabet.toNumerals(message)
+	if err != nil {
+		return plaintext, err
+	}
+	numerals, err = ff1.DecryptNumerals(numerals, tweak)
+	if err != nil {
+		return plaintext, err
+	}
+	return ff1.alphabet.fromNumerals(numerals), nil
+}
+
+// EncryptNumerals encrypts a message given as a slice of numerals, each less
+// than the radix. It works for every radix that NewFF1 accepts, and returns
+// the encrypted numerals along with any error encountered during encryption.
+// The plaintext argument should be the numerals to encrypt.
+// The tweak argument should be the tweak to use in the encryption process.
+func (ff1 *FF1) EncryptNumerals(plaintext []uint16, tweak []byte) (message []uint16, err error) {
+	message = make([]uint16, len(plaintext))
+	if err := ff1.EncryptNumeralsTo(message, plaintext, tweak); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+// DecryptNumerals decrypts a message given as a slice of numerals, each less
+// than the radix. It works for every radix that NewFF1 accepts, and returns
+// the decrypted numerals along with any error encountered during decryption.
+// The message argument should be the numerals to decrypt.
+// The tweak argument should be the tweak to use in the decryption process.
+func (ff1 *FF1) DecryptNumerals(message []uint16, tweak []byte) (plaintext []uint16, err error) {
+	plaintext = make([]uint16, len(message))
+	if err := ff1.DecryptNumeralsTo(plaintext, message, tweak); err != nil {
+		return nil, err
+	}
+	return plaintext, nil
+}
+
+// EncryptNumeralsTo encrypts a message given as a slice of numerals like
+// EncryptNumerals, writing the encrypted numerals into dst, which must be as
+// long as plaintext and may be plaintext itself. When both halves of the
+// message are below 2^64 and the tweak is at most 55 bytes, it works in
+// fixed width integers and does not allocate. It returns any error
+// encountered during encryption.
+func (ff1 *FF1) EncryptNumeralsTo(dst, plaintext []uint16, tweak []byte) error {
+	if len(dst) != len(plaintext) {
+		return errors.New("destination length did not match the message length")
+	}
+	block, err := ff1.cipher.acquire()
+	if err != nil {
+		return err
+	}
+	defer ff1.cipher.release()
+	constants, err := ff1.prepareConstants(block, plaintext, tweak)
+	if err != nil {
+		return err
+	}
+	if constants.fastPath {
+		ff1.feistelUint64(dst, plaintext, tweak, constants, false)
+	} else {
+		ff1.encryptBig(dst, plaintext, tweak, constants)
+	}
+	return nil
+}
 
-	radixPowFirstHalfLen := big.NewInt(int64(ff1.firstHalfLength))
-	radixPowFirstHalfLen.Exp(radixBig, radixPowFirstHalfLen, nil)
+// DecryptNumeralsTo decrypts a message given as a slice of numerals like
+// DecryptNumerals, writing the decrypted numerals into dst, which must be as
+// long as message and may be message itself. It does not allocate under the
+// same conditions as EncryptNumeralsTo. It returns any error encountered
+// during decryption.
+func (ff1 *FF1) DecryptNumeralsTo(dst, message []uint16, tweak []byte) error {
+	if len(dst) != len(message) {
+		return errors.New("destination length did not match the message length")
+	}
+	block, err := ff1.cipher.acquire()
+	if err != nil {
+		return err
+	}
+	defer ff1.cipher.release()
+	constants, err := ff1.prepareConstants(block, message, tweak)
+	if err != nil {
+		return err
+	}
+	if constants.fastPath {
+		ff1.feistelUint64(dst, message, tweak, constants, true)
+	} else {
+		ff1.decryptBig(dst, message, tweak, constants)
+	}
+	return nil
+}
+
+// Utility Functions for FF1
 
-	radixPowSecondHalfLen := big.NewInt(int64(ff1.secondHalfLength))
-	radixPowSecondHalfLen.Exp(radixBig, radixPowSecondHalfLen, nil)
+// encryptBig encrypts a message in big integers, for messages too large for
+// feistelUint64, and writes the result into dst.
+func (ff1 *FF1) encryptBig(dst, plaintext []uint16, tweak []byte, constants ff1Constants) {
+	firstHalf := numeralsToInt(plaintext[:constants.firstHalfLength], ff1.radix)
+	secondHalf := numeralsToInt(plaintext[constants.firstHalfLength:], ff1.radix)
 
-	variableBlockLength := len(tweak) + 1 + ff1.messageByteLength
-	variableBlockLength = variableBlockLength + (16 - variableBlockLength%16) //round variable block length to next multiple of 16 bytes (128 bits)
-	variableBlock := make([]byte, variableBlockLength)
+	variableBlock := make([]byte, constants.variableBlockLength)
 	copy(variableBlock, tweak)
 	block := make([]byte, 16)
 	for round := 0; round < 10; round++ {
-		err := ff1.adjustVariableBlock(&variableBlock, round, ff1.secondHalf)
-		if err != nil {
-			return message, err
-		}
-		ff1.pseudoRandomFunction(block, append(ff1.fixedBlock[:], variableBlock...))
-		cipheredBlockNumber := ff1.calculateCipheredBlockNumber(block)
+		ff1.adjustVariableBlock(variableBlock, round, secondHalf, constants)
+		ff1.pseudoRandomFunction(block, variableBlock, constants)
+		cipheredBlockNumber := ff1.calculateCipheredBlockNumber(block, constants)
 
-		resultStringLength, mod := ff1.secondHalfLength, radixPowSecondHalfLen
+		mod := constants.radixPowSecondHalf
 		if round%2 == 0 {
-			resultStringLength, mod = ff1.firstHalfLength, radixPowFirstHalfLen
-		}
-		firstHalfNumber, err := strconv.ParseUint(ff1.firstHalf, ff1.radix, 64)
-		if err != nil {
-			return message, err
+			mod = constants.radixPowFirstHalf
 		}
-		resultNumber := big.NewInt(int64(firstHalfNumber))
-		resultNumber.Add(resultNumber, cipheredBlockNumber)
+		resultNumber := firstHalf.Add(firstHalf, cipheredBlockNumber)
 		resultNumber.Mod(resultNumber, mod)
 
-		resultString := resultNumber.Text(ff1.radix)
-		resultString = zeroLeftPad(resultString, resultStringLength)
-		ff1.firstHalf = ff1.secondHalf
-		ff1.secondHalf = resultString
+		firstHalf = secondHalf
+		secondHalf = resultNumber
 	}
 
-	message = ff1.firstHalf + ff1.secondHalf
-	return message, nil
+	copy(dst, intToNumerals(firstHalf, ff1.radix, constants.firstHalfLength))
+	copy(dst[constants.firstHalfLength:], intToNumerals(secondHalf, ff1.radix, constants.secondHalfLength))
 }
 
-// Decrypt uses the AES key string and arguments used to construct ff1 to
-// decrypt a message. It returns the decrypted message, along with any error
-// encountered during decryption.
-// The plaintext argument should be the message to decrypt.
-// The tweak argument should be the tweak to use in the decryption process.
-func (ff1 *FF1) Decrypt(message string, tweak []byte) (plaintext string, err error) {
-	err = ff1.prepareConstants(message, tweak)
-	if err != nil {
-		return message, err
-	}
-
-	radixBig := big.NewInt(int64(ff1.radix))
-
-	radixPowFirstHalfLen := big.NewInt(int64(ff1.firstHalfLength))
-	radixPowFirstHalfLen.Exp(radixBig, radixPowFirstHalfLen, nil)
+// decryptBig decrypts a message in big integers, for messages too large for
+// feistelUint64, and writes the result into dst.
+func (ff1 *FF1) decryptBig(dst, message []uint16, tweak []byte, constants ff1Constants) {
+	firstHalf := numeralsToInt(message[:constants.firstHalfLength], ff1.radix)
+	secondHalf := numeralsToInt(message[constants.firstHalfLength:], ff1.radix)
 
-	radixPowSecondHalfLen := big.NewInt(int64(ff1.secondHalfLength))
-	radixPowSecondHalfLen.Exp(radixBig, radixPowSecondHalfLen, nil)
-
-	variableBlockLength := len(tweak) + 1 + ff1.messageByteLength
-	variableBlockLength = variableBlockLength + (16 - variableBlockLength%16) //round variable block size to next multiple of 16
-	variableBlock := make([]byte, variableBlockLength)
+	variableBlock := make([]byte, constants.variableBlockLength)
 	copy(variableBlock, tweak)
 	block := make([]byte, 16)
 	for round := 9; round >= 0; round-- {
-		err := ff1.adjustVariableBlock(&variableBlock, round, ff1.firstHalf)
-		if err != nil {
-			return message, err
-		}
-		ff1.pseudoRandomFunction(block, append(ff1.fixedBlock[:], variableBlock...))
-		cipheredBlockNumber := ff1.calculateCipheredBlockNumber(block)
+		ff1.adjustVariableBlock(variableBlock, round, firstHalf, constants)
+		ff1.pseudoRandomFunction(block, variableBlock, constants)
+		cipheredBlockNumber := ff1.calculateCipheredBlockNumber(block, constants)
 
-		resultStringLength, mod := ff1.secondHalfLength, radixPowSecondHalfLen
+		mod := constants.radixPowSecondHalf
 		if round%2 == 0 {
-			resultStringLength, mod = ff1.firstHalfLength, radixPowFirstHalfLen
+			mod = constants.radixPowFirstHalf
 		}
-		secondHalfNumber, err := strconv.ParseUint(ff1.secondHalf, ff1.radix, 64)
-		if err != nil {
-			return message, err
-		}
-		resultNumber := big.NewInt(int64(secondHalfNumber))
-		resultNumber.Sub(resultNumber, cipheredBlockNumber)
+		resultNumber := secondHalf.Sub(secondHalf, cipheredBlockNumber)
 		resultNumber.Mod(resultNumber, mod)
 
-		resultString := resultNumber.Text(ff1.radix)
-		resultString = zeroLeftPad(resultString, resultStringLength)
-		ff1.secondHalf = ff1.firstHalf
-		ff1.firstHalf = resultString
+		secondHalf = firstHalf
+		firstHalf = resultNumber
 	}
 
-	plaintext = ff1.firstHalf + ff1.secondHalf
-	return plaintext, nil
+	copy(dst, intToNumerals(firstHalf, ff1.radix, constants.firstHalfLength))
+	copy(dst[constants.firstHalfLength:], intToNumerals(secondHalf, ff1.radix, constants.secondHalfLength))
 }
 
-// Utility Functions for FF1
+// feistelUint64 encrypts or decrypts a message whose halves are below 2^64 in
+// fixed width integers, and writes the result into dst. The blocks passed to
+// the block cipher come from a pool, since passing them through the
+// cipher.Block interface would otherwise allocate them on every call.
+func (ff1 *FF1) feistelUint64(dst, message []uint16, tweak []byte, constants ff1Constants, decrypt bool) {
+	radix := uint64(ff1.radix)
+	firstHalf := numeralsToUint64(message[:constants.firstHalfLength], radix)
+	secondHalf := numeralsToUint64(message[constants.firstHalfLength:], radix)
+
+	scratch := ff1ScratchPool.Get().(*ff1Scratch)
+	variableBlock := scratch.variableBlock[:constants.variableBlockLength]
+	copy(variableBlock, tweak)
+	for i := 0; i < 10; i++ {
+		round := i
+		if decrypt {
+			round = 9 - i
+		}
+		// The half fed to the round function is B when encrypting and A when
+		// decrypting.
+		messageHalf := secondHalf
+		if decrypt {
+			messageHalf = firstHalf
+		}
+		variableBlock[len(variableBlock)-constants.messageByteLength-1] = byte(round)
+		for j := 1; j <= constants.messageByteLength; j++ {
+			variableBlock[len(variableBlock)-j] = byte(messageHalf >> uint(8*(j-1)))
+		}
+		ff1.pseudoRandomFunction(scratch.block[:], variableBlock, constants)
+
+		// With halves below 2^64, y is 8 or 12 bytes, all taken from R.
+		var high, low uint64
+		if constants.cipheredBlockLength == 12 {
+			high = uint64(binary.BigEndian.Uint32(scratch.block[0:4]))
+			low = binary.BigEndian.Uint64(scratch.block[4:12])
+		} else {
+			low = binary.BigEndian.Uint64(scratch.block[0:8])
+		}
+		mod := constants.radixPowSecondHalf64
+		if round%2 == 0 {
+			mod = constants.radixPowFirstHalf64
+		}
+		cipheredBlockNumber := bits.Rem64(high, low, mod)
+
+		if decrypt {
+			secondHalf, firstHalf = firstHalf, subMod(secondHalf, cipheredBlockNumber, mod)
+		} else {
+			firstHalf, secondHalf = secondHalf, addMod(firstHalf, cipheredBlockNumber, mod)
+		}
+	}
+	for i := range scratch.variableBlock {
+		scratch.variableBlock[i] = 0
+	}
+	ff1ScratchPool.Put(scratch)
+
+	uint64ToNumerals(dst[:constants.firstHalfLength], firstHalf, radix)
+	uint64ToNumerals(dst[constants.firstHalfLength:], secondHalf, radix)
+}
 
-// prepareConstants prepares the ff1 struct based on the given message and tweak for
-// encryption or decryption. It sets some constants that will be used in the
-// encryption or decryption calculation and returns any error that is
+// prepareConstants validates the given message and tweak for encryption or
+// decryption and returns the constants that will be used in the encryption or
+// decryption calculation with the block cipher block, computing them if they
+// are not cached yet. It returns the constants along with any error that is
 // encountered during the process.
-func (ff1 *FF1) prepareConstants(message string, tweak []byte) error {
+func (ff1 *FF1) prepareConstants(block cipher.Block, message []uint16, tweak []byte) (constants ff1Constants, err error) {
 	if len(message) <= 0 {
-		return errors.New("message length was not non-zero")
+		return constants, errors.New("message length was not non-zero")
 	}
 	if len(message) < ff1.minMessageLength {
-		return errors.New("message length was less than the minimum allowable length")
+		return constants, errors.New("message length was less than the minimum allowable length")
 	}
 	if len(message) > ff1.maxMessageLength {
-		return errors.New("message length was greater than the maximum allowable length")
+		return constants, errors.New("message length was greater than the maximum allowable length")
 	}
 	if len(tweak) > ff1.maxTweakLength {
-		return errors.New("tweak length was greater than the maximum allowable length")
+		return constants, errors.New("tweak length was greater than the maximum allowable length")
+	}
+	if err := checkNumerals(message, ff1.radix); err != nil {
+		return constants, err
 	}
 
-	ff1.messageLength = len(message)
-	ff1.firstHalfLength = ff1.messageLength / 2
-	ff1.secondHalfLength = ff1.messageLength - ff1.firstHalfLength
-	ff1.firstHalf, ff1.secondHalf = message[0:ff1.firstHalfLength], message[ff1.firstHalfLength:ff1.messageLength]
+	key := ff1ConstantsKey{messageLength: len(message), tweakLength: len(tweak)}
+	cache := ff1.constantsCache
+	cache.mutex.RLock()
+	cached, found := cache.constants[key]
+	cache.mutex.RUnlock()
+	if found {
+		constants = *cached
+		constants.cipher = block
+		return constants, nil
+	}
+
+	computed := ff1.computeConstants(block, key)
+	cache.mutex.Lock()
+	if len(cache.constants) >= maxFF1Constants {
+		cache.wipe()
+	}
+	cache.constants[key] = &computed
+	cache.mutex.Unlock()
+	constants = computed
+	constants.cipher = block
+	return constants, nil
+}
 
-	tmp := big.NewInt(int64(ff1.radix))
-	tmp.Exp(tmp, big.NewInt(int64(ff1.secondHalfLength)), nil)
-	ff1.messageByteLength = ceilRsh(ceilLog2(tmp), 3)
-	ff1.cipheredBlockLength = 4*ceilRsh(ff1.messageByteLength, 2) + 4
+// wipe zeroes the key dependent constants in the cache and empties it. The
+// caller must hold the cache's mutex for writing. Calls copy the constants
+// they use while holding it for reading, so none of them see the zeroes.
+func (cache *ff1ConstantsCache) wipe() {
+	for _, constants := range cache.constants {
+		ZeroBytes(constants.fixedBlockMAC[:])
+	}
+	cache.constants = make(map[ff1ConstantsKey]*ff1Constants)
+}
 
-	fixedBlockPart1 := uint64(0x0102010000000a00) | (uint64(ff1.radix) << 16) | uint64(ff1.firstHalfLength%256)
-	fixedBlockPart2 := (uint64(ff1.messageLength) << 32) | uint64(len(tweak))
-	binary.BigEndian.PutUint64(ff1.fixedBlock[:8], fixedBlockPart1)
-	binary.BigEndian.PutUint64(ff1.fixedBlock[8:], fixedBlockPart2)
+// computeConstants computes the constants for a message and tweak length with
+// the block cipher cph.
+func (ff1 *FF1) computeConstants(cph cipher.Block, key ff1ConstantsKey) (constants ff1Constants) {
+	constants.messageLength = key.messageLength
+	constants.firstHalfLength = constants.messageLength / 2
+	constants.secondHalfLength = constants.messageLength - constants.firstHalfLength
 
-	return nil
+	radixBig := big.NewInt(int64(ff1.radix))
+	constants.radixPowFirstHalf = new(big.Int).Exp(radixBig, big.NewInt(int64(constants.firstHalfLength)), nil)
+	constants.radixPowSecondHalf = new(big.Int).Exp(radixBig, big.NewInt(int64(constants.secondHalfLength)), nil)
+	constants.messageByteLength = ceilRsh(ceilLog2(constants.radixPowSecondHalf), 3)
+	constants.cipheredBlockLength = 4*ceilRsh(constants.messageByteLength, 2) + 4
+	constants.variableBlockLength = key.tweakLength + 1 + constants.messageByteLength
+	constants.variableBlockLength += (16 - constants.variableBlockLength%16) % 16
+	if constants.radixPowSecondHalf.BitLen() <= 64 && constants.variableBlockLength <= maxFastVariableBlockLength {
+		constants.fastPath = true
+		constants.radixPowFirstHalf64 = constants.radixPowFirstHalf.Uint64()
+		constants.radixPowSecondHalf64 = constants.radixPowSecondHalf.Uint64()
+	}
+
+	fixedBlockPart1 := uint64(0x0102010000000a00) | (uint64(ff1.radix) << 16) | uint64(constants.firstHalfLength%256)
+	fixedBlockPart2 := (uint64(constants.messageLength) << 32) | uint64(key.tweakLength)
+	binary.BigEndian.PutUint64(constants.fixedBlock[:8], fixedBlockPart1)
+	binary.BigEndian.PutUint64(constants.fixedBlock[8:], fixedBlockPart2)
+	cph.Encrypt(constants.fixedBlockMAC[:], constants.fixedBlock[:])
+
+	return constants
 }
 
 // adjustVariableBlock adjusts a variable block that changes slightly for every
-// round of the FF1 algorithm. It modifies the variableBlock argument in place
-// and returns any error encountered when parsing the message string.
+// round of the FF1 algorithm. It modifies the variableBlock argument in place.
 // The round argument should be the number of the current round in the FF1
 // algorithm.
-// The messageHalf is half of the input to the encryption or decryption round.
+// The messageHalf is the numeric value of half of the input to the encryption
+// or decryption round.
 // This can be the first half (A) or the second half (B) depending on whether
 // the function is being called during Encrypt or Decrypt.
-func (ff1 *FF1) adjustVariableBlock(variableBlock *[]byte, round int, messageHalf string) error {
-	variableBlockLength := len(*variableBlock)
-	(*variableBlock)[variableBlockLength-ff1.messageByteLength-1] = byte(round)
-	messageHalfNumber, err := strconv.ParseUint(messageHalf, ff1.radix, 64)
-	if err != nil {
-		return err
-	}
-	tmpBuf := make([]byte, 8)
-	binary.BigEndian.PutUint64(tmpBuf, messageHalfNumber)
-	copy((*variableBlock)[variableBlockLength-ff1.messageByteLength:], tmpBuf[8-ff1.messageByteLength:])
-	return nil
+// The constants argument should be the result of prepareConstants.
+func (ff1 *FF1) adjustVariableBlock(variableBlock []byte, round int, messageHalf *big.Int, constants ff1Constants) {
+	variableBlockLength := len(variableBlock)
+	variableBlock[variableBlockLength-constants.messageByteLength-1] = byte(round)
+	putBigEndian(variableBlock[variableBlockLength-constants.messageByteLength:], messageHalf)
 }
 
 // pseudoRandomFunction returns a block that has been run through an AES cipher
-// function as part of the FF1 encryption or decryption algorithm. It takes a
-// combination of the fixed and variable blocks concatenated together and
-// returns the resulting byte slice.
-func (ff1 *FF1) pseudoRandomFunction(block, blockString []byte) {
-	numBlocks := len(blockString) / 16
-	for i := 0; i < 16; i++ {
-		block[i] = 0
-	}
+// function as part of the FF1 encryption or decryption algorithm. It computes
+// the CBC-MAC of the fixed block followed by the variable block, continuing
+// from the cached state after the fixed block, and writes it to block.
+func (ff1 *FF1) pseudoRandomFunction(block, variableBlock []byte, constants ff1Constants) {
+	copy(block, constants.fixedBlockMAC[:])
 
-	for index := 0; index < numBlocks; index++ {
+	for index := 0; index < len(variableBlock); index += 16 {
 		for i := 0; i < 16; i++ {
-			block[i] ^= blockString[index*16+i]
+			block[i] ^= variableBlock[index+i]
 		}
-		ff1.cipher.Encrypt(block, block)
+		constants.cipher.Encrypt(block, block)
 	}
 }
 
 // calculateCipheredBlockNumber takes a byte slice as input and runs it through
 // an AES cipher function. It then converts the resulting byte slice into an
 // integer and returns it as a result.
-func (ff1 *FF1) calculateCipheredBlockNumber(block []byte) (cipheredBlockNumber *big.Int) {
-	byteString := make([]byte, 16*ceilRsh(ff1.cipheredBlockLength, 4))
+func (ff1 *FF1) calculateCipheredBlockNumber(block []byte, constants ff1Constants) (cipheredBlockNumber *big.Int) {
+	byteString := make([]byte, 16*ceilRsh(constants.cipheredBlockLength, 4))
 	copy(byteString[0:16], block)
-	b0 := block[0]
-	b1 := block[1]
+	// Each further block is CIPH(R xor [j]^16), where [j]^16 is the block index
+	// as a 16 byte big endian integer, so only the last bytes of R change.
+	b14 := block[14]
+	b15 := block[15]
 	for blockIndex := 1; blockIndex*16 < len(byteString); blockIndex++ {
-		block[0] = b0 ^ byte((blockIndex&0xFF00)>>8)
-		block[1] = b1 ^ byte(blockIndex&0x00FF)
+		block[14] = b14 ^ byte((blockIndex&0xFF00)>>8)
+		block[15] = b15 ^ byte(blockIndex&0x00FF)
 
-		ff1.cipher.Encrypt(byteString[blockIndex*16:(blockIndex+1)*16], block)
+		constants.cipher.Encrypt(byteString[blockIndex*16:(blockIndex+1)*16], block)
 	}
 
-	block[0] = b0
-	block[1] = b1
-	cipheredBlock := byteString[0:ff1.cipheredBlockLength]
+	block[14] = b14
+	block[15] = b15
+	cipheredBlock := byteString[0:constants.cipheredBlockLength]
 	cipheredBlockNumber = big.NewInt(0)
 	cipheredBlockNumber.SetBytes(cipheredBlock)
 	return cipheredBlockNumber
@@ -1,6 +1,10 @@
 package fpe
 
 import (
+	"bytes"
+	"crypto/aes"
+	"encoding/hex"
+	"math/rand"
 	"testing"
 )
 
@@ -16,6 +20,114 @@ func TestNewFF1WithInvalidKey(t *testing.T) {
 	assertError(t, err)
 }
 
+func TestNewFF1WithKey(t *testing.T) {
+	t.Log("Testing NewFF1WithKey with a key as bytes... ")
+	key, _ := hex.DecodeString("2B7E151628AED2A6ABF7158809CF4F3C")
+	ff1, err := NewFF1WithKey(key, 10, 2, 20, 16)
+	assertNoError(t, err)
+	ZeroBytes(key)
+	msg, err := ff1.Encrypt("0123456789", []byte{})
+	assertNoError(t, err)
+	assertExpectedResult(t, "2433477484", msg)
+}
+
+func TestFF1DestroyZeroesKey(t *testing.T) {
+	t.Log("Testing FF1 Destroy zeroes its copy of the key... ")
+	ff1, err := NewFF1("2B7E151628AED2A6ABF7158809CF4F3C", 10, 2, 20, 16)
+	assertNoError(t, err)
+	key := ff1.cipher.key
+	assertExpectedResult(t, "2b7e151628aed2a6abf7158809cf4f3c", hex.EncodeToString(key))
+	ff1.Destroy()
+	if !bytes.Equal(key, make([]byte, 16)) {
+		t.Errorf("Expected the key to be zeroed, got %x", key)
+	}
+}
+
+func TestNewFF1WithCipher(t *testing.T) {
+	t.Log("Testing NewFF1WithCipher with an AES block cipher... ")
+	key, _ := hex.DecodeString("2B7E151628AED2A6ABF7158809CF4F3C")
+	block, err := aes.NewCipher(key)
+	assertNoError(t, err)
+	ff1, err := NewFF1WithCipher(block, 10, 2, 20, 16)
+	assertNoError(t, err)
+	msg, err := ff1.Encrypt("0123456789", []byte{})
+	assertNoError(t, err)
+	assertExpectedResult(t, "2433477484", msg)
+}
+
+func TestNewFF1WithInvalidCipher(t *testing.T) {
+	t.Log("Testing NewFF1WithCipher with a nil block cipher... ")
+	_, err := NewFF1WithCipher(nil, 10, 2, 20, 16)
+	assertError(t, err)
+}
+
+func TestFF1ConstantsCache(t *testing.T) {
+	t.Log("Testing FF1 caches constants separately for each tweak length... ")
+	ff1, err := NewFF1("2B7E151628AED2A6ABF7158809CF4F3C", 10, 2, 20, 16)
+	assertNoError(t, err)
+	tweak := []byte{0x39, 0x38, 0x37, 0x36, 0x35, 0x34, 0x33, 0x32, 0x31, 0x30}
+	for i := 0; i < 2; i++ {
+		msg, err := ff1.Encrypt("0123456789", []byte{})
+		assertNoError(t, err)
+		assertExpectedResult(t, "2433477484", msg)
+		msg, err = ff1.Encrypt("0123456789", tweak)
+		assertNoError(t, err)
+		assertExpectedResult(t, "6124200773", msg)
+	}
+	if len(ff1.constantsCache.constants) != 2 {
+		t.Errorf("Expected 2 cached constants, got %d", len(ff1.constantsCache.constants))
+	}
+	cached := make([]*ff1Constants, 0, 2)
+	for _, constants := range ff1.constantsCache.constants {
+		cached = append(cached, constants)
+	}
+	ff1.Destroy()
+	if len(ff1.constantsCache.constants) != 0 {
+		t.Errorf("Expected no cached constants, got %d", len(ff1.constantsCache.constants))
+	}
+	for _, constants := range cached {
+		if constants.fixedBlockMAC != [16]byte{} {
+			t.Errorf("Expected the cached fixed block MAC to be zeroed, got %x", constants.fixedBlockMAC)
+		}
+	}
+}
+
+func TestFF1ConstantsCacheBounded(t *testing.T) {
+	t.Log("Testing FF1 caches at most maxFF1Constants sets of constants... ")
+	ff1, err := NewFF1("2B7E151628AED2A6ABF7158809CF4F3C", 10, 2, 20, 2*maxFF1Constants)
+	assertNoError(t, err)
+	for tweakLength := 0; tweakLength <= 2*maxFF1Constants; tweakLength++ {
+		_, err := ff1.Encrypt("0123456789", make([]byte, tweakLength))
+		assertNoError(t, err)
+		if len(ff1.constantsCache.constants) > maxFF1Constants {
+			t.Fatalf("Expected at most %d cached constants, got %d", maxFF1Constants, len(ff1.constantsCache.constants))
+		}
+	}
+	msg, err := ff1.Encrypt("0123456789", []byte{})
+	assertNoError(t, err)
+	assertExpectedResult(t, "2433477484", msg)
+}
+
+func TestFF1Destroy(t *testing.T) {
+	t.Log("Testing FF1 refuses to encrypt or decrypt once destroyed... ")
+	ff1, err := NewFF1("2B7E151628AED2A6ABF7158809CF4F3C", 10, 2, 20, 16)
+	assertNoError(t, err)
+	copied := ff1
+	ff1.Destroy()
+	_, err = ff1.Encrypt("0123456789", []byte{})
+	if err != ErrDestroyed {
+		t.Errorf("Expected ErrDestroyed, got %v", err)
+	}
+	_, err = copied.Encrypt("0123456789", []byte{})
+	if err != ErrDestroyed {
+		t.Errorf("Expected ErrDestroyed from a copy, got %v", err)
+	}
+	_, err = ff1.Decrypt("2433477484", []byte{})
+	if err != ErrDestroyed {
+		t.Errorf("Expected ErrDestroyed, got %v", err)
+	}
+}
+
 func TestFF1Encrypt1(t *testing.T) {
 	t.Log("Testing FF1 encryption (case 1)... ")
 	ff1, err := NewFF1("2B7E151628AED2A6ABF7158809CF4F3C", 10, 2, 20, 16)
@@ -177,6 +289,78 @@ func TestFF1Decrypt9(t *testing.T) {
 	assertExpectedResult(t, "0123456789abcdefghi", plaintext)
 }
 
+func TestFF1EncryptLong1(t *testing.T) {
+	t.Log("Testing FF1 encryption with a 60 digit message... ")
+	ff1, err := NewFF1("2B7E151628AED2A6ABF7158809CF4F3C", 10, 2, 60, 16)
+	assertNoError(t, err)
+	msg, err := ff1.Encrypt("012345678901234567890123456789012345678901234567890123456789", []byte{})
+	assertNoError(t, err)
+	assertExpectedResult(t, "845795790607044343519325592150236625695334728536538299011761", msg)
+}
+
+func TestFF1DecryptLong1(t *testing.T) {
+	t.Log("Testing FF1 decryption with a 60 digit message... ")
+	ff1, err := NewFF1("2B7E151628AED2A6ABF7158809CF4F3C", 10, 2, 60, 16)
+	assertNoError(t, err)
+	plaintext, err := ff1.Decrypt("845795790607044343519325592150236625695334728536538299011761", []byte{})
+	assertNoError(t, err)
+	assertExpectedResult(t, "012345678901234567890123456789012345678901234567890123456789", plaintext)
+}
+
+func TestFF1EncryptLong2(t *testing.T) {
+	t.Log("Testing FF1 encryption with a 40 character radix 36 message... ")
+	ff1, err := NewFF1("2B7E151628AED2A6ABF7158809CF4F3CEF4359D8D580AA4F7F036D6F04FC6A94", 36, 2, 60, 16)
+	assertNoError(t, err)
+	msg, err := ff1.Encrypt("0123456789abcdefghijklmnopqrstuvwxyz0123", []byte{0x37, 0x37, 0x37, 0x37, 0x70, 0x71, 0x72, 0x73, 0x37, 0x37, 0x37})
+	assertNoError(t, err)
+	assertExpectedResult(t, "2hx8mtyy9ignhpm4a271suvar4c0jkhv33ugrm2e", msg)
+}
+
+func TestFF1DecryptLong2(t *testing.T) {
+	t.Log("Testing FF1 decryption with a 40 character radix 36 message... ")
+	ff1, err := NewFF1("2B7E151628AED2A6ABF7158809CF4F3CEF4359D8D580AA4F7F036D6F04FC6A94", 36, 2, 60, 16)
+	assertNoError(t, err)
+	plaintext, err := ff1.Decrypt("2hx8mtyy9ignhpm4a271suvar4c0jkhv33ugrm2e", []byte{0x37, 0x37, 0x37, 0x37, 0x70, 0x71, 0x72, 0x73, 0x37, 0x37, 0x37})
+	assertNoError(t, err)
+	assertExpectedResult(t, "0123456789abcdefghijklmnopqrstuvwxyz0123", plaintext)
+}
+
+func TestFF1EncryptLong3(t *testing.T) {
+	t.Log("Testing FF1 encryption with a 40 digit message and 13 byte tweak... ")
+	ff1, err := NewFF1("2B7E151628AED2A6ABF7158809CF4F3C", 10, 2, 60, 16)
+	assertNoError(t, err)
+	msg, err := ff1.Encrypt("1234567890123456789012345678901234567890", []byte{0x39, 0x38, 0x37, 0x36, 0x35, 0x34, 0x33, 0x32, 0x31, 0x30, 0x31, 0x32, 0x33})
+	assertNoError(t, err)
+	assertExpectedResult(t, "8564302035695645606854362477981011673320", msg)
+}
+
+func TestFF1DecryptLong3(t *testing.T) {
+	t.Log("Testing FF1 decryption with a 40 digit message and 13 byte tweak... ")
+	ff1, err := NewFF1("2B7E151628AED2A6ABF7158809CF4F3C", 10, 2, 60, 16)
+	assertNoError(t, err)
+	plaintext, err := ff1.Decrypt("8564302035695645606854362477981011673320", []byte{0x39, 0x38, 0x37, 0x36, 0x35, 0x34, 0x33, 0x32, 0x31, 0x30, 0x31, 0x32, 0x33})
+	assertNoError(t, err)
+	assertExpectedResult(t, "1234567890123456789012345678901234567890", plaintext)
+}
+
+func TestFF1EncryptLong4(t *testing.T) {
+	t.Log("Testing FF1 encryption where the variable block needs no padding... ")
+	ff1, err := NewFF1("2B7E151628AED2A6ABF7158809CF4F3C", 10, 2, 60, 16)
+	assertNoError(t, err)
+	msg, err := ff1.Encrypt("1234567890123456789012345678901234567890", []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06})
+	assertNoError(t, err)
+	assertExpectedResult(t, "1831899690188000477445235979918765248005", msg)
+}
+
+func TestFF1DecryptLong4(t *testing.T) {
+	t.Log("Testing FF1 decryption where the variable block needs no padding... ")
+	ff1, err := NewFF1("2B7E151628AED2A6ABF7158809CF4F3C", 10, 2, 60, 16)
+	assertNoError(t, err)
+	plaintext, err := ff1.Decrypt("1831899690188000477445235979918765248005", []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06})
+	assertNoError(t, err)
+	assertExpectedResult(t, "1234567890123456789012345678901234567890", plaintext)
+}
+
 func TestFF1EncryptNoMessage(t *testing.T) {
 	t.Log("Testing FF1 encryption with no message... ")
 	ff1, err := NewFF1("2B7E151628AED2A6ABF7158809CF4F3CEF4359D8D580AA4F7F036D6F04FC6A94", 36, 2, 20, 16)
@@ -273,6 +457,66 @@ func TestFF1DecryptLongTweak(t *testing.T) {
 	assertError(t, err)
 }
 
+func TestFF1FastPath(t *testing.T) {
+	t.Log("Testing the FF1 uint64 fast path matches the big integer path... ")
+	random := rand.New(rand.NewSource(1))
+	cases := []struct{ radix, length int }{
+		{2, 20}, {10, 9}, {10, 19}, {10, 38}, {36, 24}, {62, 20}, {1000, 12}, {65536, 6},
+	}
+	for _, c := range cases {
+		ff1, err := NewFF1("2B7E151628AED2A6ABF7158809CF4F3C", c.radix, c.length, c.length, 16)
+		assertNoError(t, err)
+		for i := 0; i < 50; i++ {
+			plaintext := make([]uint16, c.length)
+			for j := range plaintext {
+				plaintext[j] = uint16(random.Intn(c.radix))
+			}
+			tweak := make([]byte, random.Intn(17))
+			random.Read(tweak)
+
+			constants, err := ff1.prepareConstants(ff1.cipher.block, plaintext, tweak)
+			assertNoError(t, err)
+			if !constants.fastPath {
+				t.Fatalf("Expected the fast path for radix %d and length %d", c.radix, c.length)
+			}
+			expected := make([]uint16, c.length)
+			ff1.encryptBig(expected, plaintext, tweak, constants)
+			message, err := ff1.EncryptNumerals(plaintext, tweak)
+			assertNoError(t, err)
+			assertExpectedNumerals(t, expected, message)
+
+			ff1.decryptBig(expected, message, tweak, constants)
+			assertExpectedNumerals(t, plaintext, expected)
+			assertNoError(t, ff1.DecryptNumeralsTo(message, message, tweak))
+			assertExpectedNumerals(t, plaintext, message)
+		}
+	}
+}
+
+func TestFF1SlowPath(t *testing.T) {
+	t.Log("Testing FF1 uses big integers when a half does not fit in 64 bits... ")
+	ff1, err := NewFF1("2B7E151628AED2A6ABF7158809CF4F3C", 10, 2, 40, 16)
+	assertNoError(t, err)
+	plaintext := make([]uint16, 40)
+	constants, err := ff1.prepareConstants(ff1.cipher.block, plaintext, []byte{})
+	assertNoError(t, err)
+	if constants.fastPath {
+		t.Fatalf("Expected the big integer path for 40 digits")
+	}
+	message, err := ff1.EncryptNumerals(plaintext, []byte{})
+	assertNoError(t, err)
+	decrypted, err := ff1.DecryptNumerals(message, []byte{})
+	assertNoError(t, err)
+	assertExpectedNumerals(t, plaintext, decrypted)
+}
+
+func TestFF1EncryptNumeralsToWrongLength(t *testing.T) {
+	t.Log("Testing FF1 EncryptNumeralsTo refuses a destination of the wrong length... ")
+	ff1, err := NewFF1("2B7E151628AED2A6ABF7158809CF4F3C", 10, 2, 20, 16)
+	assertNoError(t, err)
+	assertError(t, ff1.EncryptNumeralsTo(make([]uint16, 9), make([]uint16, 10), []byte{}))
+}
+
 func BenchmarkFF1Encrypt(b *testing.B) {
 	ff1, _ := NewFF1("2B7E151628AED2A6ABF7158809CF4F3C", 10, 2, 20, 16)
 
@@ -288,3 +532,83 @@ func BenchmarkFF1Decrypt(b *testing.B) {
 		ff1.Decrypt("2433477484", []byte{})
 	}
 }
+
+func BenchmarkFF1EncryptSSN(b *testing.B) {
+	ff1, _ := NewFF1("2B7E151628AED2A6ABF7158809CF4F3C", 10, 9, 9, 16)
+
+	for i := 0; i < b.N; i++ {
+		ff1.Encrypt("078051120", []byte{})
+	}
+}
+
+// BenchmarkFF1EncryptSSNUncached clears the per-length constants before every
+// call, to compare with BenchmarkFF1EncryptSSN.
+func BenchmarkFF1EncryptSSNUncached(b *testing.B) {
+	ff1, _ := NewFF1("2B7E151628AED2A6ABF7158809CF4F3C", 10, 9, 9, 16)
+
+	for i := 0; i < b.N; i++ {
+		ff1.constantsCache = &ff1ConstantsCache{constants: make(map[ff1ConstantsKey]*ff1Constants)}
+		ff1.Encrypt("078051120", []byte{})
+	}
+}
+
+func BenchmarkFF1PrepareConstants(b *testing.B) {
+	ff1, _ := NewFF1("2B7E151628AED2A6ABF7158809CF4F3C", 10, 9, 9, 16)
+	message := []uint16{0, 7, 8, 0, 5, 1, 1, 2, 0}
+
+	for i := 0; i < b.N; i++ {
+		ff1.prepareConstants(ff1.cipher.block, message, []byte{})
+	}
+}
+
+func BenchmarkFF1ComputeConstants(b *testing.B) {
+	ff1, _ := NewFF1("2B7E151628AED2A6ABF7158809CF4F3C", 10, 9, 9, 16)
+	key := ff1ConstantsKey{messageLength: 9, tweakLength: 0}
+
+	for i := 0; i < b.N; i++ {
+		ff1.computeConstants(ff1.cipher.block, key)
+	}
+}
+
+func BenchmarkFF1EncryptSSNParallel(b *testing.B) {
+	ff1, _ := NewFF1("2B7E151628AED2A6ABF7158809CF4F3C", 10, 9, 9, 16)
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			ff1.Encrypt("078051120", []byte{})
+		}
+	})
+}
+
+func BenchmarkFF1EncryptNumeralsTo(b *testing.B) {
+	ff1, _ := NewFF1("2B7E151628AED2A6ABF7158809CF4F3C", 10, 9, 9, 16)
+	plaintext := []uint16{0, 7, 8, 0, 5, 1, 1, 2, 0}
+	message := make([]uint16, len(plaintext))
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		ff1.EncryptNumeralsTo(message, plaintext, []byte{})
+	}
+}
+
+func BenchmarkFF1DecryptNumeralsTo(b *testing.B) {
+	ff1, _ := NewFF1("2B7E151628AED2A6ABF7158809CF4F3C", 10, 9, 9, 16)
+	message := []uint16{6, 5, 3, 9, 1, 8, 2, 6, 8}
+	plaintext := make([]uint16, len(message))
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		ff1.DecryptNumeralsTo(plaintext, message, []byte{})
+	}
+}
+
+func BenchmarkFF1EncryptNumeralsTo19Digits(b *testing.B) {
+	ff1, _ := NewFF1("2B7E151628AED2A6ABF7158809CF4F3C", 10, 2, 38, 16)
+	plaintext := []uint16{4, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1}
+	message := make([]uint16, len(plaintext))
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		ff1.EncryptNumeralsTo(message, plaintext, []byte{0x39, 0x38, 0x37, 0x36, 0x35, 0x34, 0x33})
+	}
+}
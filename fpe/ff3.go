@@ -3,37 +3,57 @@ package fpe
 import (
 	"crypto/aes"
 	"crypto/cipher"
+	"encoding/binary"
 	"encoding/hex"
 	"errors"
 	"math/big"
-	"strconv"
+	"math/bits"
+	"sync"
 )
 
 // The FF3 type allows for encryption and decryption of messages using the FF3
 // mode of format preserving encryption. See the NewFF3, (ff3 *FF3) Encrypt,
 // and (ff3 *FF3) Decrypt functions for more detail.
 type FF3 struct {
-	cipher           cipher.Block
+	cipher           *destroyableBlock
+	alphabet         *Alphabet
 	radix            int
 	minMessageLength int
 	maxMessageLength int
+}
 
-	messageLength    int
+// The ff3Constants type holds the values that depend on the message and tweak
+// being encrypted or decrypted. They are computed for every call so that a
+// single FF3 can be shared between goroutines.
+type ff3Constants struct {
 	firstHalfLength  int
 	secondHalfLength int
-	firstHalf        string
-	secondHalf       string
 	tweakLeft        [4]byte
 	tweakRight       [4]byte
+	// fastPath is whether radix^u and radix^v are below 2^64, so
+	// feistelUint64 can be used. radixPowFirstHalf64 and radixPowSecondHalf64
+	// are then radix^u and radix^v.
+	fastPath             bool
+	radixPowFirstHalf64  uint64
+	radixPowSecondHalf64 uint64
+	// cipher is the block cipher of the FF3, acquired for each call.
+	cipher cipher.Block
 }
 
+// ff3BlockPool reuses the blocks feistelUint64 passes to the block cipher.
+var ff3BlockPool = sync.Pool{New: func() interface{} { return new([16]byte) }}
+
 // NewFF3 returns a new FF3 struct for encrypting and decrypting messages using
 // the FF3 mode of format preserving encryption. It will also return any errors
 // encountered in creating an AES key.
 // The keyString argument should be the AES key string in hexadecimal, either
 // 16, 24, or 32 bytes to select AES-128, AES-192, or AES-256.
 // The radix argument should be the number of characters in the alphabet that
-// will be used. It can be any integer from 2 to 36 inclusive.
+// will be used. It can be any integer from 2 to 65536 inclusive. Messages are
+// written with the first radix characters of 0-9a-zA-Z, ignoring the case of
+// letters when the radix is 36 or less. Encrypt and Decrypt can only be used
+// with a radix above 62 if the FF3 was made with NewFF3WithAlphabet; otherwise
+// use EncryptNumerals and DecryptNumerals.
 // The minMessageLength and maxMessageLength arguments should be the minimum
 // and maximum message lengths that will be allowed.
 func NewFF3(keyString string, radix, minMessageLength, maxMessageLength int) (ff3 FF3, err error) {
@@ -41,10 +61,41 @@ func NewFF3(keyString string, radix, minMessageLength, maxMessageLength int) (ff
 	if err != nil {
 		return FF3{}, err
 	}
-	cph, err := aes.NewCipher(reverseBytes(key))
+	defer ZeroBytes(key)
+
+	return NewFF3WithKey(key, radix, minMessageLength, maxMessageLength)
+}
+
+// NewFF3WithKey returns a new FF3 struct like NewFF3, with the AES key given
+// as 16, 24, or 32 bytes instead of a hexadecimal string. The FF3 keeps its
+// own copy of the key until Destroy zeroes it, so the caller can zero key once
+// NewFF3WithKey returns.
+func NewFF3WithKey(key []byte, radix, minMessageLength, maxMessageLength int) (ff3 FF3, err error) {
+	reversedKey := reverseBytes(key)
+	defer ZeroBytes(reversedKey)
+	cph, err := aes.NewCipher(reversedKey)
+	if err != nil {
+		return FF3{}, err
+	}
+
+	ff3, err = NewFF3WithCipher(cph, radix, minMessageLength, maxMessageLength)
 	if err != nil {
 		return FF3{}, err
 	}
+	ff3.cipher = newDestroyableBlock(cph, key)
+	return ff3, nil
+}
+
+// NewFF3WithCipher returns a new FF3 struct like NewFF3, using a ready made
+// block cipher instead of creating one from a key. This allows keys held by
+// an HSM or another implementation of AES to be used directly. FF3 keys AES
+// with the bytes of the key in reverse order, so the block cipher must be
+// made from the reversed key to match NewFF3 and NewFF3WithKey. It must have
+// a 16 byte block size and be safe for concurrent use.
+func NewFF3WithCipher(block cipher.Block, radix, minMessageLength, maxMessageLength int) (ff3 FF3, err error) {
+	if block == nil || block.BlockSize() != aes.BlockSize {
+		return FF3{}, errors.New("cipher must have a 16 byte block size")
+	}
 
 	if radix < 2 || radix > 65536 {
 		return FF3{}, errors.New("radix must be in [2..2^16]")
@@ -66,149 +117,336 @@ func NewFF3(keyString string, radix, minMessageLength, maxMessageLength int) (ff
 
 	bigMinLen := big.NewInt(int64(minMessageLength))
 	if bigTmp.Exp(bigRadix, bigMinLen, nil).Cmp(big.NewInt(int64(100))) < 0 {
-		return FF3{}, errors.New("radix^minlen >= 100")
+		return FF3{}, errors.New("radix^minlen >= 100: use NewSmallDomain for smaller domains")
 	}
 
 	return FF3{
-		cipher:           cph,
+		cipher:           newDestroyableBlock(block, nil),
+		alphabet:         defaultAlphabet(radix),
 		radix:            radix,
 		minMessageLength: minMessageLength,
 		maxMessageLength: maxMessageLength}, nil
 }
 
+// NewFF3WithAlphabet returns a new FF3 struct like NewFF3, except that messages
+// are written using the characters of the given alphabet instead of the first
+// radix characters of 0-9a-zA-Z. The radix is the number of characters in the
+// alphabet.
+func NewFF3WithAlphabet(keyString string, alphabet Alphabet, minMessageLength, maxMessageLength int) (ff3 FF3, err error) {
+	ff3, err = NewFF3(keyString, alphabet.Radix(), minMessageLength, maxMessageLength)
+	if err != nil {
+		return FF3{}, err
+	}
+
+	ff3.alphabet = &alphabet
+	return ff3, nil
+}
+
+// MinimumDomainSize returns the number of messages of the minimum length,
+// which is radix^minlen.
+func (ff3 *FF3) MinimumDomainSize() *big.Int {
+	return powInt(ff3.radix, ff3.minMessageLength)
+}
+
+// Destroy zeroes the copy of the key held by ff3 and drops its block cipher,
+// after which encryption and decryption with ff3 or any copy of it return
+// ErrDestroyed. It waits for calls already under way on other goroutines to
+// finish first. As with (ff1 *FF1) Destroy, the AES key schedule cannot be
+// zeroed and is only dropped.
+func (ff3 *FF3) Destroy() {
+	ff3.cipher.destroy(nil)
+}
+
 // Encrypt uses the AES key string and arguments used to construct ff3 to
 // encrypt a message. It returns the encrypted message, along with any error
-// encountered during encryption.
+// encountered during encryption. It is safe to call Encrypt and Decrypt
+// concurrently on the same FF3.
 // The plaintext argument should be the message to encrypt.
 // The tweak argument should be the tweak to use in the encryption process.
 func (ff3 *FF3) Encrypt(plaintext string, tweak []byte) (message string, err error) {
-	err = ff3.prepareConstants(plaintext, tweak)
+	if ff3.alphabet == nil {
+		return message, errors.New("radix has no default alphabet: use EncryptNumerals or NewFF3WithAlphabet")
+	}
+	numerals, err := ff3.alphabet.toNumerals(plaintext)
+	if err != nil {
+		return message, err
+	}
+	numerals, err = ff3.EncryptNumerals(numerals, tweak)
 	if err != nil {
 		return message, err
 	}
+	return ff3.alphabet.fromNumerals(numerals), nil
+}
 
-	radixBig := big.NewInt(int64(ff3.radix))
+// Decrypt uses the AES key string and arguments used to construct ff3 to
+// decrypt a message. It returns the decrypted message, along with any error
+// encountered during decryption. It is safe to call Encrypt and Decrypt
+// concurrently on the same FF3.
+// The plaintext argument should be the message to decrypt.
+// The tweak argument should be the tweak to use in the decryption process.
+func (ff3 *FF3) Decrypt(message string, tweak []byte) (plaintext string, err error) {
+	if ff3.alphabet == nil {
+		return plaintext, errors.New("radix has no default alphabet: use DecryptNumerals or NewFF3WithAlphabet")
+	}
+	numerals, err := ff3.alphabet.toNumerals(message)
+	if err != nil {
+		return plaintext, err
+	}
+	numerals, err = ff3.DecryptNumerals(numerals, tweak)
+	if err != nil {
+		return plaintext, err
+	}
+	return ff3.alphabet.fromNumerals(numerals), nil
+}
+
+// EncryptNumerals encrypts a message given as a slice of numerals, each less
+// than the radix. It works for every radix that NewFF3 accepts, and returns
+// the encrypted numerals along with any error encountered during encryption.
+// The plaintext argument should be the numerals to encrypt.
+// The tweak argument should be the tweak to use in the encryption process.
+func (ff3 *FF3) EncryptNumerals(plaintext []uint16, tweak []byte) (message []uint16, err error) {
+	message = make([]uint16, len(plaintext))
+	if err := ff3.EncryptNumeralsTo(message, plaintext, tweak); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+// DecryptNumerals decrypts a message given as a slice of numerals, each less
+// than the radix. It works for every radix that NewFF3 accepts, and returns
+// the decrypted numerals along with any error encountered during decryption.
+// The message argument should be the numerals to decrypt.
+// The tweak argument should be the tweak to use in the decryption process.
+func (ff3 *FF3) DecryptNumerals(message []uint16, tweak []byte) (plaintext []uint16, err error) {
+	plaintext = make([]uint16, len(message))
+	if err := ff3.DecryptNumeralsTo(plaintext, message, tweak); err != nil {
+		return nil, err
+	}
+	return plaintext, nil
+}
+
+// EncryptNumeralsTo encrypts a message given as a slice of numerals like
+// EncryptNumerals, writing the encrypted numerals into dst, which must be as
+// long as plaintext and may be plaintext itself. When both halves of the
+// message are below 2^64, it works in fixed width integers and does not
+// allocate. It returns any error encountered during encryption.
+func (ff3 *FF3) EncryptNumeralsTo(dst, plaintext []uint16, tweak []byte) error {
+	if len(dst) != len(plaintext) {
+		return errors.New("destination length did not match the message length")
+	}
+	block, err := ff3.cipher.acquire()
+	if err != nil {
+		return err
+	}
+	defer ff3.cipher.release()
+	constants, err := ff3.prepareConstants(block, plaintext, tweak)
+	if err != nil {
+		return err
+	}
+	if constants.fastPath {
+		ff3.feistelUint64(dst, plaintext, constants, false)
+		return nil
+	}
+	return ff3.encryptBig(dst, plaintext, constants)
+}
 
-	radixPowFirstHalfLen := big.NewInt(int64(ff3.firstHalfLength))
-	radixPowFirstHalfLen.Exp(radixBig, radixPowFirstHalfLen, nil)
+// DecryptNumeralsTo decrypts a message given as a slice of numerals like
+// DecryptNumerals, writing the decrypted numerals into dst, which must be as
+// long as message and may be message itself. It does not allocate under the
+// same conditions as EncryptNumeralsTo. It returns any error encountered
+// during decryption.
+func (ff3 *FF3) DecryptNumeralsTo(dst, message []uint16, tweak []byte) error {
+	if len(dst) != len(message) {
+		return errors.New("destination length did not match the message length")
+	}
+	block, err := ff3.cipher.acquire()
+	if err != nil {
+		return err
+	}
+	defer ff3.cipher.release()
+	constants, err := ff3.prepareConstants(block, message, tweak)
+	if err != nil {
+		return err
+	}
+	if constants.fastPath {
+		ff3.feistelUint64(dst, message, constants, true)
+		return nil
+	}
+	return ff3.decryptBig(dst, message, constants)
+}
 
-	radixPowSecondHalfLen := big.NewInt(int64(ff3.secondHalfLength))
-	radixPowSecondHalfLen.Exp(radixBig, radixPowSecondHalfLen, nil)
+// Utility Functions for FF3
+
+// encryptBig encrypts a message in big integers, for messages too large for
+// feistelUint64, and writes the result into dst.
+func (ff3 *FF3) encryptBig(dst, plaintext []uint16, constants ff3Constants) error {
+	// The halves are kept as the numbers NUM_radix(REV(A)) and NUM_radix(REV(B)).
+	firstHalf := numeralsToInt(reverseNumerals(plaintext[:constants.firstHalfLength]), ff3.radix)
+	secondHalf := numeralsToInt(reverseNumerals(plaintext[constants.firstHalfLength:]), ff3.radix)
+
+	radixBig := big.NewInt(int64(ff3.radix))
+	radixPowFirstHalfLen := new(big.Int).Exp(radixBig, big.NewInt(int64(constants.firstHalfLength)), nil)
+	radixPowSecondHalfLen := new(big.Int).Exp(radixBig, big.NewInt(int64(constants.secondHalfLength)), nil)
 
 	for round := 0; round < 8; round++ {
-		resultStringLength, tweakHalf, mod := ff3.secondHalfLength, ff3.tweakLeft, radixPowSecondHalfLen
+		tweakHalf, mod := constants.tweakLeft, radixPowSecondHalfLen
 		if round%2 == 0 {
-			resultStringLength, tweakHalf, mod = ff3.firstHalfLength, ff3.tweakRight, radixPowFirstHalfLen
+			tweakHalf, mod = constants.tweakRight, radixPowFirstHalfLen
 		}
 
-		cipheredBlockNumber, err := ff3.calculateCipheredBlockNumber(round, ff3.secondHalf, tweakHalf)
+		cipheredBlockNumber, err := ff3.calculateCipheredBlockNumber(constants.cipher, round, secondHalf, tweakHalf)
 		if err != nil {
-			return message, err
+			return err
 		}
 
-		firstHalfNumber, err := strconv.ParseUint(reverse(ff3.firstHalf), ff3.radix, 64)
-		if err != nil {
-			return message, err
-		}
-		resultNumber := big.NewInt(int64(firstHalfNumber))
-		resultNumber.Add(resultNumber, cipheredBlockNumber)
+		resultNumber := firstHalf.Add(firstHalf, cipheredBlockNumber)
 		resultNumber.Mod(resultNumber, mod)
-		resultString := resultNumber.Text(ff3.radix)
-		resultString = reverse(zeroLeftPad(resultString, resultStringLength))
 
-		ff3.firstHalf = ff3.secondHalf
-		ff3.secondHalf = resultString
+		firstHalf = secondHalf
+		secondHalf = resultNumber
 	}
 
-	message = ff3.firstHalf + ff3.secondHalf
-	return message, nil
+	copy(dst, reverseNumerals(intToNumerals(firstHalf, ff3.radix, constants.firstHalfLength)))
+	copy(dst[constants.firstHalfLength:], reverseNumerals(intToNumerals(secondHalf, ff3.radix, constants.secondHalfLength)))
+	return nil
 }
 
-// Decrypt uses the AES key string and arguments used to construct ff3 to
-// decrypt a message. It returns the decrypted message, along with any error
-// encountered during decryption.
-// The plaintext argument should be the message to decrypt.
-// The tweak argument should be the tweak to use in the decryption process.
-func (ff3 *FF3) Decrypt(message string, tweak []byte) (plaintext string, err error) {
-	err = ff3.prepareConstants(message, tweak)
-	if err != nil {
-		return plaintext, err
-	}
+// decryptBig decrypts a message in big integers, for messages too large for
+// feistelUint64, and writes the result into dst.
+func (ff3 *FF3) decryptBig(dst, message []uint16, constants ff3Constants) error {
+	// The halves are kept as the numbers NUM_radix(REV(A)) and NUM_radix(REV(B)).
+	firstHalf := numeralsToInt(reverseNumerals(message[:constants.firstHalfLength]), ff3.radix)
+	secondHalf := numeralsToInt(reverseNumerals(message[constants.firstHalfLength:]), ff3.radix)
 
 	radixBig := big.NewInt(int64(ff3.radix))
-
-	radixPowFirstHalfLen := big.NewInt(int64(ff3.firstHalfLength))
-	radixPowFirstHalfLen.Exp(radixBig, radixPowFirstHalfLen, nil)
-
-	radixPowSecondHalfLen := big.NewInt(int64(ff3.secondHalfLength))
-	radixPowSecondHalfLen.Exp(radixBig, radixPowSecondHalfLen, nil)
+	radixPowFirstHalfLen := new(big.Int).Exp(radixBig, big.NewInt(int64(constants.firstHalfLength)), nil)
+	radixPowSecondHalfLen := new(big.Int).Exp(radixBig, big.NewInt(int64(constants.secondHalfLength)), nil)
 
 	for round := 7; round >= 0; round-- {
-		resultStringLength, tweakHalf, mod := ff3.secondHalfLength, ff3.tweakLeft, radixPowSecondHalfLen
+		tweakHalf, mod := constants.tweakLeft, radixPowSecondHalfLen
 		if round%2 == 0 {
-			resultStringLength, tweakHalf, mod = ff3.firstHalfLength, ff3.tweakRight, radixPowFirstHalfLen
+			tweakHalf, mod = constants.tweakRight, radixPowFirstHalfLen
 		}
 
-		cipheredBlockNumber, err := ff3.calculateCipheredBlockNumber(round, ff3.firstHalf, tweakHalf)
+		cipheredBlockNumber, err := ff3.calculateCipheredBlockNumber(constants.cipher, round, firstHalf, tweakHalf)
 		if err != nil {
-			return plaintext, err
+			return err
 		}
 
-		firstHalfNumber, err := strconv.ParseUint(reverse(ff3.secondHalf), ff3.radix, 64)
-		if err != nil {
-			return plaintext, err
-		}
-		resultNumber := big.NewInt(int64(firstHalfNumber))
-		resultNumber.Sub(resultNumber, cipheredBlockNumber)
+		resultNumber := secondHalf.Sub(secondHalf, cipheredBlockNumber)
 		resultNumber.Mod(resultNumber, mod)
-		resultString := resultNumber.Text(ff3.radix)
-		resultString = reverse(zeroLeftPad(resultString, resultStringLength))
 
-		ff3.secondHalf = ff3.firstHalf
-		ff3.firstHalf = resultString
+		secondHalf = firstHalf
+		firstHalf = resultNumber
 	}
 
-	plaintext = ff3.firstHalf + ff3.secondHalf
-	return plaintext, nil
+	copy(dst, reverseNumerals(intToNumerals(firstHalf, ff3.radix, constants.firstHalfLength)))
+	copy(dst[constants.firstHalfLength:], reverseNumerals(intToNumerals(secondHalf, ff3.radix, constants.secondHalfLength)))
+	return nil
 }
 
-// Utility Functions for FF3
+// feistelUint64 encrypts or decrypts a message whose halves are below 2^64 in
+// fixed width integers, and writes the result into dst. The block passed to
+// the block cipher comes from a pool, since passing it through the
+// cipher.Block interface would otherwise allocate it on every call.
+func (ff3 *FF3) feistelUint64(dst, message []uint16, constants ff3Constants, decrypt bool) {
+	radix := uint64(ff3.radix)
+	// The halves are kept as the numbers NUM_radix(REV(A)) and NUM_radix(REV(B)),
+	// so the least significant numeral of each comes first.
+	firstHalf, secondHalf := uint64(0), uint64(0)
+	for i := constants.firstHalfLength - 1; i >= 0; i-- {
+		firstHalf = firstHalf*radix + uint64(message[i])
+	}
+	for i := len(message) - 1; i >= constants.firstHalfLength; i-- {
+		secondHalf = secondHalf*radix + uint64(message[i])
+	}
+
+	block := ff3BlockPool.Get().(*[16]byte)
+	for i := 0; i < 8; i++ {
+		round := i
+		if decrypt {
+			round = 7 - i
+		}
+		tweakHalf, mod := constants.tweakLeft, constants.radixPowSecondHalf64
+		if round%2 == 0 {
+			tweakHalf, mod = constants.tweakRight, constants.radixPowFirstHalf64
+		}
+		messageHalf := secondHalf
+		if decrypt {
+			messageHalf = firstHalf
+		}
 
-// prepareConstants prepares the ff3 struct based on the given message and tweak for
-// encryption or decryption. It sets some constants that will be used in the
-// encryption or decryption calculation and returns any error that is
-// encountered during the process.
-func (ff3 *FF3) prepareConstants(message string, tweak []byte) error {
+		// This is REVB(W xor [i]^4 || [messageHalf]^12), the block that
+		// calculateCipheredBlockNumber encrypts.
+		binary.LittleEndian.PutUint64(block[0:8], messageHalf)
+		binary.LittleEndian.PutUint32(block[8:12], 0)
+		block[12] = tweakHalf[3] ^ byte(round)
+		block[13] = tweakHalf[2]
+		block[14] = tweakHalf[1]
+		block[15] = tweakHalf[0]
+		constants.cipher.Encrypt(block[:], block[:])
+		// NUM(REVB(block)) reads the block as a little endian number.
+		cipheredBlockNumber := bits.Rem64(binary.LittleEndian.Uint64(block[8:16]),
+			binary.LittleEndian.Uint64(block[0:8]), mod)
+
+		if decrypt {
+			secondHalf, firstHalf = firstHalf, subMod(secondHalf, cipheredBlockNumber, mod)
+		} else {
+			firstHalf, secondHalf = secondHalf, addMod(firstHalf, cipheredBlockNumber, mod)
+		}
+	}
+	*block = [16]byte{}
+	ff3BlockPool.Put(block)
+
+	for i := 0; i < constants.firstHalfLength; i++ {
+		dst[i] = uint16(firstHalf % radix)
+		firstHalf /= radix
+	}
+	for i := constants.firstHalfLength; i < len(dst); i++ {
+		dst[i] = uint16(secondHalf % radix)
+		secondHalf /= radix
+	}
+}
+
+// prepareConstants validates the given message and tweak for encryption or
+// decryption and computes some constants that will be used in the encryption
+// or decryption calculation with the block cipher block. It returns the
+// constants along with any error that is encountered during the process.
+func (ff3 *FF3) prepareConstants(block cipher.Block, message []uint16, tweak []byte) (constants ff3Constants, err error) {
+	constants.cipher = block
 	if len(message) <= 0 {
-		return errors.New("message length was not non-zero")
+		return constants, errors.New("message length was not non-zero")
 	}
 	if len(message) < ff3.minMessageLength {
-		return errors.New("message length was less than the minimum allowable length")
+		return constants, errors.New("message length was less than the minimum allowable length")
 	}
 	if len(message) > ff3.maxMessageLength {
-		return errors.New("message length was greater than the maximum allowable length")
+		return constants, errors.New("message length was greater than the maximum allowable length")
 	}
 	if len(tweak) != 8 {
-		return errors.New("tweak length was not 8 bytes")
+		return constants, errors.New("tweak length was not 8 bytes")
+	}
+	if err := checkNumerals(message, ff3.radix); err != nil {
+		return constants, err
 	}
 
-	ff3.messageLength = len(message)
-	ff3.firstHalfLength = ceilRsh(ff3.messageLength, 1)
-	ff3.secondHalfLength = ff3.messageLength - ff3.firstHalfLength
-	ff3.firstHalf, ff3.secondHalf = message[0:ff3.firstHalfLength], message[ff3.firstHalfLength:ff3.messageLength]
-	copy(ff3.tweakLeft[:], tweak[0:4])
-	copy(ff3.tweakRight[:], tweak[4:8])
+	constants.firstHalfLength = ceilRsh(len(message), 1)
+	constants.secondHalfLength = len(message) - constants.firstHalfLength
+	copy(constants.tweakLeft[:], tweak[0:4])
+	copy(constants.tweakRight[:], tweak[4:8])
+	constants.radixPowFirstHalf64, constants.fastPath = powUint64(uint64(ff3.radix), constants.firstHalfLength)
+	constants.radixPowSecondHalf64, _ = powUint64(uint64(ff3.radix), constants.secondHalfLength)
 
-	return nil
+	return constants, nil
 }
 
 // calculateCipheredBlockNumber assembles a block based on the round number of
-// the FF3 encryption or decryption algorithm, half of the message being
-// encrypted or decrypted, and half of the tweak. It then runs the block through
-// an AES cipher function, converts the resulting byte slice into an
-// integer, and returns the integer as a result along with any error that is
-// encountered in parsing the message string.
-func (ff3 *FF3) calculateCipheredBlockNumber(round int, messageHalf string, tweakHalf [4]byte) (cipheredBlockNumber *big.Int, err error) {
+// the FF3 encryption or decryption algorithm, the value of the reversed half of
+// the message being encrypted or decrypted, and half of the tweak. It then runs
+// the block through an AES cipher function, converts the resulting byte slice
+// into an integer, and returns the integer as a result along with any error
+// that is encountered if the half of the message does not fit in the block.
+func (ff3 *FF3) calculateCipheredBlockNumber(cph cipher.Block, round int, messageHalf *big.Int, tweakHalf [4]byte) (cipheredBlockNumber *big.Int, err error) {
 	block := [16]byte{}
 	cipheredBlock := [16]byte{}
 
@@ -218,19 +456,13 @@ func (ff3 *FF3) calculateCipheredBlockNumber(round int, messageHalf string, twea
 	block[2] = tweakHalf[2] ^ byte(round & 0x0000FF00 >> 8)
 	block[3] = tweakHalf[3] ^ byte(round & 0x000000FF)
 
-	reverseSecondHalf := reverse(messageHalf)
-	reverseSecondHalfNumber := big.NewInt(0)
-	if _, ok := reverseSecondHalfNumber.SetString(reverseSecondHalf, ff3.radix); ok {
-		tmp := reverseSecondHalfNumber.Bytes()
-		if len(tmp) > 12 {
-			return cipheredBlockNumber, errors.New("message was too long: half the message cannot fit in 12 bytes")
-		}
-		copy(block[16-len(tmp):16], tmp)
-	} else {
-		return cipheredBlockNumber, errors.New("couldn't interpret numerical string")
+	tmp := messageHalf.Bytes()
+	if len(tmp) > 12 {
+		return cipheredBlockNumber, errors.New("message was too long: half the message cannot fit in 12 bytes")
 	}
+	copy(block[16-len(tmp):16], tmp)
 
-	ff3.cipher.Encrypt(cipheredBlock[:], reverseBytes(block[:]))
+	cph.Encrypt(cipheredBlock[:], reverseBytes(block[:]))
 	copy(cipheredBlock[:], reverseBytes(cipheredBlock[:]))
 	cipheredBlockNumber = big.NewInt(0).SetBytes(cipheredBlock[:])
 
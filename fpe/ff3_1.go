@@ -0,0 +1,190 @@
+package fpe
+
+import (
+	"crypto/cipher"
+	"errors"
+	"math/big"
+)
+
+// The FF31 type allows for encryption and decryption of messages using the
+// FF3-1 mode of format preserving encryption described in NIST SP 800-38G
+// Rev.1. FF3-1 replaces the original FF3 mode, which was withdrawn after the
+// Durak-Vaudenay attack, by shortening the tweak to 56 bits. See the NewFF31,
+// (ff31 *FF31) Encrypt, and (ff31 *FF31) Decrypt functions for more detail.
+type FF31 struct {
+	ff3 FF3
+}
+
+// NewFF31 returns a new FF31 struct for encrypting and decrypting messages
+// using the FF3-1 mode of format preserving encryption. It will also return any
+// errors encountered in creating an AES key.
+// The keyString argument should be the AES key string in hexadecimal, either
+// 16, 24, or 32 bytes to select AES-128, AES-192, or AES-256.
+// The radix argument should be the number of characters in the alphabet that
+// will be used. It can be any integer from 2 to 65536 inclusive. Messages are
+// written with the first radix characters of 0-9a-zA-Z, ignoring the case of
+// letters when the radix is 36 or less. Encrypt and Decrypt can only be used
+// with a radix above 62 if the FF31 was made with NewFF31WithAlphabet;
+// otherwise use EncryptNumerals and DecryptNumerals.
+// The minMessageLength and maxMessageLength arguments should be the minimum
+// and maximum message lengths that will be allowed.
+func NewFF31(keyString string, radix, minMessageLength, maxMessageLength int) (ff31 FF31, err error) {
+	ff3, err := NewFF3(keyString, radix, minMessageLength, maxMessageLength)
+	if err != nil {
+		return FF31{}, err
+	}
+
+	return FF31{ff3: ff3}, nil
+}
+
+// NewFF31WithKey returns a new FF31 struct like NewFF31, with the AES key
+// given as 16, 24, or 32 bytes instead of a hexadecimal string. The key is not
+// kept, so the caller can zero it once NewFF31WithKey returns.
+func NewFF31WithKey(key []byte, radix, minMessageLength, maxMessageLength int) (ff31 FF31, err error) {
+	ff3, err := NewFF3WithKey(key, radix, minMessageLength, maxMessageLength)
+	if err != nil {
+		return FF31{}, err
+	}
+
+	return FF31{ff3: ff3}, nil
+}
+
+// NewFF31WithCipher returns a new FF31 struct like NewFF31, using a ready made
+// block cipher instead of creating one from a key. As with NewFF3WithCipher,
+// the block cipher must be made from the key with its bytes reversed.
+func NewFF31WithCipher(block cipher.Block, radix, minMessageLength, maxMessageLength int) (ff31 FF31, err error) {
+	ff3, err := NewFF3WithCipher(block, radix, minMessageLength, maxMessageLength)
+	if err != nil {
+		return FF31{}, err
+	}
+
+	return FF31{ff3: ff3}, nil
+}
+
+// NewFF31WithAlphabet returns a new FF31 struct like NewFF31, except that
+// messages are written using the characters of the given alphabet instead of
+// the first radix characters of 0-9a-zA-Z.
+func NewFF31WithAlphabet(keyString string, alphabet Alphabet, minMessageLength, maxMessageLength int) (ff31 FF31, err error) {
+	ff3, err := NewFF3WithAlphabet(keyString, alphabet, minMessageLength, maxMessageLength)
+	if err != nil {
+		return FF31{}, err
+	}
+
+	return FF31{ff3: ff3}, nil
+}
+
+// MinimumDomainSize returns the number of messages of the minimum length,
+// which is radix^minlen.
+func (ff31 *FF31) MinimumDomainSize() *big.Int {
+	return ff31.ff3.MinimumDomainSize()
+}
+
+// Destroy zeroes the key of ff31 and drops its block cipher like
+// (ff3 *FF3) Destroy, after which encryption and decryption return
+// ErrDestroyed.
+func (ff31 *FF31) Destroy() {
+	ff31.ff3.Destroy()
+}
+
+// Encrypt uses the AES key string and arguments used to construct ff31 to
+// encrypt a message. It returns the encrypted message, along with any error
+// encountered during encryption.
+// The plaintext argument should be the message to encrypt.
+// The tweak argument should be the 7 byte (56 bit) tweak to use in the
+// encryption process.
+func (ff31 *FF31) Encrypt(plaintext string, tweak []byte) (message string, err error) {
+	expandedTweak, err := expandTweak(tweak)
+	if err != nil {
+		return message, err
+	}
+
+	return ff31.ff3.Encrypt(plaintext, expandedTweak[:])
+}
+
+// Decrypt uses the AES key string and arguments used to construct ff31 to
+// decrypt a message. It returns the decrypted message, along with any error
+// encountered during decryption.
+// The message argument should be the message to decrypt.
+// The tweak argument should be the 7 byte (56 bit) tweak to use in the
+// decryption process.
+func (ff31 *FF31) Decrypt(message string, tweak []byte) (plaintext string, err error) {
+	expandedTweak, err := expandTweak(tweak)
+	if err != nil {
+		return plaintext, err
+	}
+
+	return ff31.ff3.Decrypt(message, expandedTweak[:])
+}
+
+// EncryptNumerals encrypts a message given as a slice of numerals, each less
+// than the radix, and returns the encrypted numerals along with any error
+// encountered during encryption.
+// The plaintext argument should be the numerals to encrypt.
+// The tweak argument should be the 7 byte (56 bit) tweak to use in the
+// encryption process.
+func (ff31 *FF31) EncryptNumerals(plaintext []uint16, tweak []byte) (message []uint16, err error) {
+	expandedTweak, err := expandTweak(tweak)
+	if err != nil {
+		return message, err
+	}
+
+	return ff31.ff3.EncryptNumerals(plaintext, expandedTweak[:])
+}
+
+// DecryptNumerals decrypts a message given as a slice of numerals, each less
+// than the radix, and returns the decrypted numerals along with any error
+// encountered during decryption.
+// The message argument should be the numerals to decrypt.
+// The tweak argument should be the 7 byte (56 bit) tweak to use in the
+// decryption process.
+func (ff31 *FF31) DecryptNumerals(message []uint16, tweak []byte) (plaintext []uint16, err error) {
+	expandedTweak, err := expandTweak(tweak)
+	if err != nil {
+		return plaintext, err
+	}
+
+	return ff31.ff3.DecryptNumerals(message, expandedTweak[:])
+}
+
+// EncryptNumeralsTo encrypts a message given as a slice of numerals like
+// EncryptNumerals, writing the encrypted numerals into dst like
+// (ff3 *FF3) EncryptNumeralsTo. It returns any error encountered during
+// encryption.
+func (ff31 *FF31) EncryptNumeralsTo(dst, plaintext []uint16, tweak []byte) error {
+	expandedTweak, err := expandTweak(tweak)
+	if err != nil {
+		return err
+	}
+
+	return ff31.ff3.EncryptNumeralsTo(dst, plaintext, expandedTweak[:])
+}
+
+// DecryptNumeralsTo decrypts a message given as a slice of numerals like
+// DecryptNumerals, writing the decrypted numerals into dst like
+// (ff3 *FF3) DecryptNumeralsTo. It returns any error encountered during
+// decryption.
+func (ff31 *FF31) DecryptNumeralsTo(dst, message []uint16, tweak []byte) error {
+	expandedTweak, err := expandTweak(tweak)
+	if err != nil {
+		return err
+	}
+
+	return ff31.ff3.DecryptNumeralsTo(dst, message, expandedTweak[:])
+}
+
+// Utility Functions for FF3-1
+
+// expandTweak splits a 56 bit FF3-1 tweak into the two 32 bit halves used by
+// the FF3 rounds and returns them concatenated as an 8 byte tweak. Following
+// SP 800-38G Rev.1, the left half is T[0..27] || 0^4 and the right half is
+// T[32..55] || T[28..31] || 0^4.
+func expandTweak(tweak []byte) ([8]byte, error) {
+	if len(tweak) != 7 {
+		return [8]byte{}, errors.New("tweak length was not 7 bytes")
+	}
+
+	return [8]byte{
+		tweak[0], tweak[1], tweak[2], tweak[3] & 0xF0,
+		tweak[4], tweak[5], tweak[6], tweak[3] << 4,
+	}, nil
+}
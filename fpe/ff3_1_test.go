@@ -0,0 +1,123 @@
+package fpe
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestNewFF31WithInvalidHexString(t *testing.T) {
+	t.Log("Testing NewFF31 with invalid hex string... ")
+	_, err := NewFF31("2B7E151628AED2A6XYZ7158809CF4F3C", 10, 2, 20)
+	assertError(t, err)
+}
+
+func TestNewFF31WithInvalidKey(t *testing.T) {
+	t.Log("Testing NewFF31 with invalid AES key... ")
+	_, err := NewFF31("A1B2C3", 10, 2, 20)
+	assertError(t, err)
+}
+
+func TestNewFF31WithKey(t *testing.T) {
+	t.Log("Testing NewFF31WithKey with a key as bytes... ")
+	key, _ := hex.DecodeString("EF4359D8D580AA4F7F036D6F04FC6A94")
+	ff31, err := NewFF31WithKey(key, 10, 2, 20)
+	assertNoError(t, err)
+	msg, err := ff31.Encrypt("890121234567890000", []byte{0xD8, 0xE7, 0x92, 0x0A, 0xFA, 0x33, 0x0A})
+	assertNoError(t, err)
+	assertExpectedResult(t, "477064185124354662", msg)
+}
+
+func TestFF31Encrypt1(t *testing.T) {
+	t.Log("Testing FF3-1 encryption (case 1)... ")
+	ff31, err := NewFF31("EF4359D8D580AA4F7F036D6F04FC6A94", 10, 2, 20)
+	assertNoError(t, err)
+	msg, err := ff31.Encrypt("890121234567890000", []byte{0xD8, 0xE7, 0x92, 0x0A, 0xFA, 0x33, 0x0A})
+	assertNoError(t, err)
+	assertExpectedResult(t, "477064185124354662", msg)
+}
+
+func TestFF31Decrypt1(t *testing.T) {
+	t.Log("Testing FF3-1 decryption (case 1)...")
+	ff31, err := NewFF31("EF4359D8D580AA4F7F036D6F04FC6A94", 10, 2, 20)
+	assertNoError(t, err)
+	plaintext, err := ff31.Decrypt("477064185124354662", []byte{0xD8, 0xE7, 0x92, 0x0A, 0xFA, 0x33, 0x0A})
+	assertNoError(t, err)
+	assertExpectedResult(t, "890121234567890000", plaintext)
+}
+
+func TestFF31Encrypt2(t *testing.T) {
+	t.Log("Testing FF3-1 encryption (case 2)... ")
+	ff31, err := NewFF31("2DE79D232DF5585D68CE47882AE256D6", 10, 2, 20)
+	assertNoError(t, err)
+	msg, err := ff31.Encrypt("3992520240", []byte{0xCB, 0xD0, 0x92, 0x80, 0x97, 0x95, 0x64})
+	assertNoError(t, err)
+	assertExpectedResult(t, "8901801106", msg)
+}
+
+func TestFF31Decrypt2(t *testing.T) {
+	t.Log("Testing FF3-1 decryption (case 2)...")
+	ff31, err := NewFF31("2DE79D232DF5585D68CE47882AE256D6", 10, 2, 20)
+	assertNoError(t, err)
+	plaintext, err := ff31.Decrypt("8901801106", []byte{0xCB, 0xD0, 0x92, 0x80, 0x97, 0x95, 0x64})
+	assertNoError(t, err)
+	assertExpectedResult(t, "3992520240", plaintext)
+}
+
+func TestFF31EncryptInvalidTweak(t *testing.T) {
+	t.Log("Testing FF3-1 encryption with an 8 byte tweak... ")
+	ff31, err := NewFF31("EF4359D8D580AA4F7F036D6F04FC6A94", 10, 2, 20)
+	assertNoError(t, err)
+	_, err = ff31.Encrypt("890121234567890000", []byte{0xD8, 0xE7, 0x92, 0x0A, 0xFA, 0x33, 0x0A, 0x73})
+	assertError(t, err)
+}
+
+func TestFF31DecryptInvalidTweak(t *testing.T) {
+	t.Log("Testing FF3-1 decryption with an 8 byte tweak... ")
+	ff31, err := NewFF31("EF4359D8D580AA4F7F036D6F04FC6A94", 10, 2, 20)
+	assertNoError(t, err)
+	_, err = ff31.Decrypt("477064185124354662", []byte{0xD8, 0xE7, 0x92, 0x0A, 0xFA, 0x33, 0x0A, 0x73})
+	assertError(t, err)
+}
+
+func TestFF31EncryptShortMessage(t *testing.T) {
+	t.Log("Testing FF3-1 encryption with message that is too short... ")
+	ff31, err := NewFF31("EF4359D8D580AA4F7F036D6F04FC6A94", 36, 5, 20)
+	assertNoError(t, err)
+	_, err = ff31.Encrypt("1234", []byte{0xD8, 0xE7, 0x92, 0x0A, 0xFA, 0x33, 0x0A})
+	assertError(t, err)
+}
+
+func TestFF31DecryptShortMessage(t *testing.T) {
+	t.Log("Testing FF3-1 decryption with message that is too short... ")
+	ff31, err := NewFF31("EF4359D8D580AA4F7F036D6F04FC6A94", 36, 5, 20)
+	assertNoError(t, err)
+	_, err = ff31.Decrypt("1234", []byte{0xD8, 0xE7, 0x92, 0x0A, 0xFA, 0x33, 0x0A})
+	assertError(t, err)
+}
+
+func TestFF31EncryptNumeralsTo(t *testing.T) {
+	t.Log("Testing FF3-1 encryption into a destination slice... ")
+	ff31, err := NewFF31("EF4359D8D580AA4F7F036D6F04FC6A94", 10, 2, 20)
+	assertNoError(t, err)
+	tweak := []byte{0xD8, 0xE7, 0x92, 0x0A, 0xFA, 0x33, 0x0A}
+	plaintext := []uint16{8, 9, 0, 1, 2, 1, 2, 3, 4, 5, 6, 7, 8, 9, 0, 0, 0, 0}
+	expected, err := ff31.EncryptNumerals(plaintext, tweak)
+	assertNoError(t, err)
+	message := make([]uint16, len(plaintext))
+	assertNoError(t, ff31.EncryptNumeralsTo(message, plaintext, tweak))
+	assertExpectedNumerals(t, expected, message)
+	assertNoError(t, ff31.DecryptNumeralsTo(message, message, tweak))
+	assertExpectedNumerals(t, plaintext, message)
+}
+
+func BenchmarkFF31EncryptNumeralsTo(b *testing.B) {
+	ff31, _ := NewFF31("EF4359D8D580AA4F7F036D6F04FC6A94", 10, 2, 20)
+	tweak := []byte{0xD8, 0xE7, 0x92, 0x0A, 0xFA, 0x33, 0x0A}
+	plaintext := []uint16{8, 9, 0, 1, 2, 1, 2, 3, 4, 5, 6, 7, 8, 9, 0, 0, 0, 0}
+	message := make([]uint16, len(plaintext))
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		ff31.EncryptNumeralsTo(message, plaintext, tweak)
+	}
+}
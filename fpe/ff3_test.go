@@ -1,6 +1,9 @@
 package fpe
 
 import (
+	"crypto/aes"
+	"encoding/hex"
+	"math/rand"
 	"testing"
 )
 
@@ -16,6 +19,39 @@ func TestNewFF3WithInvalidKey(t *testing.T) {
 	assertError(t, err)
 }
 
+func TestNewFF3WithKey(t *testing.T) {
+	t.Log("Testing NewFF3WithKey with a key as bytes... ")
+	key, _ := hex.DecodeString("EF4359D8D580AA4F7F036D6F04FC6A94")
+	ff3, err := NewFF3WithKey(key, 10, 2, 20)
+	assertNoError(t, err)
+	msg, err := ff3.Encrypt("890121234567890000", []byte{0xD8, 0xE7, 0x92, 0x0A, 0xFA, 0x33, 0x0A, 0x73})
+	assertNoError(t, err)
+	assertExpectedResult(t, "750918814058654607", msg)
+}
+
+func TestNewFF3WithCipher(t *testing.T) {
+	t.Log("Testing NewFF3WithCipher with an AES block cipher of the reversed key... ")
+	key, _ := hex.DecodeString("EF4359D8D580AA4F7F036D6F04FC6A94")
+	block, err := aes.NewCipher(reverseBytes(key))
+	assertNoError(t, err)
+	ff3, err := NewFF3WithCipher(block, 10, 2, 20)
+	assertNoError(t, err)
+	msg, err := ff3.Encrypt("890121234567890000", []byte{0xD8, 0xE7, 0x92, 0x0A, 0xFA, 0x33, 0x0A, 0x73})
+	assertNoError(t, err)
+	assertExpectedResult(t, "750918814058654607", msg)
+}
+
+func TestFF3Destroy(t *testing.T) {
+	t.Log("Testing FF3 refuses to encrypt once destroyed... ")
+	ff3, err := NewFF3("EF4359D8D580AA4F7F036D6F04FC6A94", 10, 2, 20)
+	assertNoError(t, err)
+	ff3.Destroy()
+	_, err = ff3.Encrypt("890121234567890000", []byte{0xD8, 0xE7, 0x92, 0x0A, 0xFA, 0x33, 0x0A, 0x73})
+	if err != ErrDestroyed {
+		t.Errorf("Expected ErrDestroyed, got %v", err)
+	}
+}
+
 func TestFF3Encrypt1(t *testing.T) {
 	t.Log("Testing FF3 encryption (case 1)... ")
 	ff3, err := NewFF3("EF4359D8D580AA4F7F036D6F04FC6A94", 10, 2, 20)
@@ -398,6 +434,68 @@ func TestFF3DecryptInvalidMessage2(t *testing.T) {
 	assertError(t, err)
 }
 
+func TestFF3FastPath(t *testing.T) {
+	t.Log("Testing the FF3 uint64 fast path matches the big integer path... ")
+	random := rand.New(rand.NewSource(1))
+	cases := []struct{ radix, length int }{
+		{2, 40}, {10, 9}, {10, 19}, {10, 38}, {36, 24}, {62, 20}, {1000, 12}, {65536, 6},
+	}
+	for _, c := range cases {
+		ff3, err := NewFF3("EF4359D8D580AA4F7F036D6F04FC6A94", c.radix, c.length, c.length)
+		assertNoError(t, err)
+		for i := 0; i < 50; i++ {
+			plaintext := make([]uint16, c.length)
+			for j := range plaintext {
+				plaintext[j] = uint16(random.Intn(c.radix))
+			}
+			tweak := make([]byte, 8)
+			random.Read(tweak)
+
+			constants, err := ff3.prepareConstants(ff3.cipher.block, plaintext, tweak)
+			assertNoError(t, err)
+			if !constants.fastPath {
+				t.Fatalf("Expected the fast path for radix %d and length %d", c.radix, c.length)
+			}
+			expected := make([]uint16, c.length)
+			assertNoError(t, ff3.encryptBig(expected, plaintext, constants))
+			message, err := ff3.EncryptNumerals(plaintext, tweak)
+			assertNoError(t, err)
+			assertExpectedNumerals(t, expected, message)
+
+			assertNoError(t, ff3.decryptBig(expected, message, constants))
+			assertExpectedNumerals(t, plaintext, expected)
+			assertNoError(t, ff3.DecryptNumeralsTo(message, message, tweak))
+			assertExpectedNumerals(t, plaintext, message)
+		}
+	}
+}
+
+func TestFF3SlowPath(t *testing.T) {
+	t.Log("Testing FF3 uses big integers when a half does not fit in 64 bits... ")
+	ff3, err := NewFF3("EF4359D8D580AA4F7F036D6F04FC6A94", 10, 2, 56)
+	assertNoError(t, err)
+	plaintext := make([]uint16, 40)
+	tweak := []byte{0xD8, 0xE7, 0x92, 0x0A, 0xFA, 0x33, 0x0A, 0x73}
+	constants, err := ff3.prepareConstants(ff3.cipher.block, plaintext, tweak)
+	assertNoError(t, err)
+	if constants.fastPath {
+		t.Fatalf("Expected the big integer path for 40 digits")
+	}
+	message, err := ff3.EncryptNumerals(plaintext, tweak)
+	assertNoError(t, err)
+	decrypted, err := ff3.DecryptNumerals(message, tweak)
+	assertNoError(t, err)
+	assertExpectedNumerals(t, plaintext, decrypted)
+}
+
+func TestFF3EncryptNumeralsToWrongLength(t *testing.T) {
+	t.Log("Testing FF3 EncryptNumeralsTo refuses a destination of the wrong length... ")
+	ff3, err := NewFF3("EF4359D8D580AA4F7F036D6F04FC6A94", 10, 2, 20)
+	assertNoError(t, err)
+	tweak := []byte{0xD8, 0xE7, 0x92, 0x0A, 0xFA, 0x33, 0x0A, 0x73}
+	assertError(t, ff3.EncryptNumeralsTo(make([]uint16, 11), make([]uint16, 10), tweak))
+}
+
 func BenchmarkFF3Encrypt(b *testing.B) {
 	ff3, _ := NewFF3("EF4359D8D580AA4F7F036D6F04FC6A94", 10, 2, 20)
 	tweak := []byte{0xD8, 0xE7, 0x92, 0x0A, 0xFA, 0x33, 0x0A, 0x73}
@@ -415,3 +513,27 @@ func BenchmarkFF3Decrypt(b *testing.B) {
 		ff3.Decrypt("750918814058654607", tweak)
 	}
 }
+
+func BenchmarkFF3EncryptNumeralsTo(b *testing.B) {
+	ff3, _ := NewFF3("EF4359D8D580AA4F7F036D6F04FC6A94", 10, 2, 20)
+	tweak := []byte{0xD8, 0xE7, 0x92, 0x0A, 0xFA, 0x33, 0x0A, 0x73}
+	plaintext := []uint16{8, 9, 0, 1, 2, 1, 2, 3, 4, 5, 6, 7, 8, 9, 0, 0, 0, 0}
+	message := make([]uint16, len(plaintext))
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		ff3.EncryptNumeralsTo(message, plaintext, tweak)
+	}
+}
+
+func BenchmarkFF3DecryptNumeralsTo(b *testing.B) {
+	ff3, _ := NewFF3("EF4359D8D580AA4F7F036D6F04FC6A94", 10, 2, 20)
+	tweak := []byte{0xD8, 0xE7, 0x92, 0x0A, 0xFA, 0x33, 0x0A, 0x73}
+	message := []uint16{7, 5, 0, 9, 1, 8, 8, 1, 4, 0, 5, 8, 6, 5, 4, 6, 0, 7}
+	plaintext := make([]uint16, len(message))
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		ff3.DecryptNumeralsTo(plaintext, message, tweak)
+	}
+}
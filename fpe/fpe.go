@@ -1,6 +1,85 @@
 package fpe
 
+import (
+	"crypto/cipher"
+	"errors"
+	"sync"
+)
+
+// ErrDestroyed is returned when encrypting or decrypting with an algorithm
+// whose Destroy method has been called.
+var ErrDestroyed = errors.New("algorithm was destroyed")
+
+// The destroyableBlock type holds the block cipher of an FF1, FF3 or
+// SmallDomain, and a copy of the raw key it was made from, if it was made from
+// one. Copies of the algorithm share it, so destroying it through one copy
+// destroys it for all of them. Each call holds it for reading while it uses
+// the block cipher and anything computed from it, so destroy waits for calls
+// under way and can then zero what they used without racing them.
+type destroyableBlock struct {
+	mutex sync.RWMutex
+	block cipher.Block
+	key   []byte
+}
+
+// newDestroyableBlock returns a new destroyableBlock holding block and a copy
+// of key, which may be nil when block was not made from a key held here.
+func newDestroyableBlock(block cipher.Block, key []byte) *destroyableBlock {
+	destroyable := &destroyableBlock{block: block}
+	if key != nil {
+		destroyable.key = append([]byte(nil), key...)
+	}
+	return destroyable
+}
+
+// acquire returns the block cipher, which the caller must release once it is
+// done with it, or ErrDestroyed once it has been destroyed.
+func (destroyable *destroyableBlock) acquire() (cipher.Block, error) {
+	if destroyable == nil {
+		return nil, ErrDestroyed
+	}
+	destroyable.mutex.RLock()
+	if destroyable.block == nil {
+		destroyable.mutex.RUnlock()
+		return nil, ErrDestroyed
+	}
+	return destroyable.block, nil
+}
+
+// release ends a call to acquire that returned the block cipher.
+func (destroyable *destroyableBlock) release() {
+	destroyable.mutex.RUnlock()
+}
+
+// destroy waits for the calls that acquired the block cipher to release it,
+// then calls wipe, if it is not nil, to zero what was computed from it, zeroes
+// the copy of the key and drops the block cipher, so that acquire returns
+// ErrDestroyed.
+func (destroyable *destroyableBlock) destroy(wipe func()) {
+	if destroyable == nil {
+		return
+	}
+	destroyable.mutex.Lock()
+	defer destroyable.mutex.Unlock()
+	if wipe != nil {
+		wipe()
+	}
+	ZeroBytes(destroyable.key)
+	destroyable.key = nil
+	destroyable.block = nil
+}
+
+// The Algorithm interface is implemented by every mode of format preserving
+// encryption in this package. Implementations are safe for concurrent use, so
+// a single instance can be shared between goroutines.
 type Algorithm interface {
 	Encrypt(plaintext string, tweak []byte) (message string, err error)
 	Decrypt(message string, tweak []byte) (plaintext string, err error)
 }
+
+// ZeroBytes overwrites key material with zeros once it is no longer needed.
+func ZeroBytes(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
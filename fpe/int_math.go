@@ -1,6 +1,7 @@
 package fpe
 
 import (
+	"errors"
 	"math/big"
 )
 
@@ -31,3 +32,56 @@ func ceilLog2(x *big.Int) int {
 
 	return n
 }
+
+// numeralsToInt returns the number represented by a slice of numerals in the
+// given radix, most significant numeral first. This is NUM_radix(X) in
+// SP 800-38G.
+func numeralsToInt(numerals []uint16, radix int) *big.Int {
+	x := big.NewInt(0)
+	bigRadix := big.NewInt(int64(radix))
+	numeral := big.NewInt(0)
+	for _, n := range numerals {
+		x.Mul(x, bigRadix)
+		x.Add(x, numeral.SetInt64(int64(n)))
+	}
+	return x
+}
+
+// intToNumerals returns the length numerals that represent x in the given
+// radix, most significant numeral first. This is STR^m_radix(x) in
+// SP 800-38G. x must be less than radix^length.
+func intToNumerals(x *big.Int, radix, length int) []uint16 {
+	numerals := make([]uint16, length)
+	bigRadix := big.NewInt(int64(radix))
+	quotient := new(big.Int).Set(x)
+	remainder := big.NewInt(0)
+	for i := length - 1; i >= 0; i-- {
+		quotient.QuoRem(quotient, bigRadix, remainder)
+		numerals[i] = uint16(remainder.Int64())
+	}
+	return numerals
+}
+
+// checkNumerals returns an error if any of the numerals is not less than the
+// radix.
+func checkNumerals(numerals []uint16, radix int) error {
+	for _, n := range numerals {
+		if int(n) >= radix {
+			return errors.New("message contained a numeral that was not less than the radix")
+		}
+	}
+	return nil
+}
+
+// putBigEndian writes x into dst as an unsigned big endian integer that fills
+// all of dst, truncating the most significant bytes if x does not fit.
+func putBigEndian(dst []byte, x *big.Int) {
+	b := x.Bytes()
+	for i := range dst {
+		dst[i] = 0
+	}
+	if len(b) > len(dst) {
+		b = b[len(b)-len(dst):]
+	}
+	copy(dst[len(dst)-len(b):], b)
+}
@@ -0,0 +1,157 @@
+package fpe
+
+import (
+	"errors"
+	"math/big"
+)
+
+// minRangeBits is the smallest number of bits an IntegerRange encrypts with
+// FF1, since FF1 needs a domain of at least 100 messages.
+const minRangeBits = 7
+
+// The IntegerRange type allows for encryption and decryption of integers in a
+// range, such that each integer encrypts to another integer in the same range.
+// The offset of an integer from the start of the range is encrypted as a
+// binary FF1 message, cycle walking until the result is inside the range. See
+// the NewIntegerRange, (integerRange *IntegerRange) Encrypt, and
+// (integerRange *IntegerRange) Decrypt functions for more detail.
+type IntegerRange struct {
+	ff1     FF1
+	minimum *big.Int
+	maximum *big.Int
+	// size is the number of integers in the range.
+	size *big.Int
+	// bits is the length of the binary FF1 messages.
+	bits int
+}
+
+// NewIntegerRange returns a new IntegerRange struct for encrypting and
+// decrypting integers from minimum to maximum inclusive. It will also return
+// any errors encountered in creating an AES key.
+// The keyString argument should be the AES key string in hexadecimal, either
+// 16, 24, or 32 bytes to select AES-128, AES-192, or AES-256.
+// The minimum and maximum arguments should be the bounds of the range, which
+// must hold at least 2 integers.
+// The maxTweakLength argument should be the maximum length of tweaks, in bytes.
+func NewIntegerRange(keyString string, minimum, maximum *big.Int, maxTweakLength int) (integerRange IntegerRange, err error) {
+	size := new(big.Int).Sub(maximum, minimum)
+	size.Add(size, big.NewInt(1))
+	if size.Cmp(big.NewInt(2)) < 0 {
+		return IntegerRange{}, errors.New("range must hold at least 2 integers")
+	}
+
+	bits := offsetBits(size)
+	ff1, err := NewFF1(keyString, 2, bits, bits, maxTweakLength)
+	if err != nil {
+		return IntegerRange{}, err
+	}
+
+	return IntegerRange{
+		ff1:     ff1,
+		minimum: new(big.Int).Set(minimum),
+		maximum: new(big.Int).Set(maximum),
+		size:    size,
+		bits:    bits}, nil
+}
+
+// NewIntegerRangeInt64 returns a new IntegerRange struct like NewIntegerRange,
+// with bounds given as int64 values.
+func NewIntegerRangeInt64(keyString string, minimum, maximum int64, maxTweakLength int) (integerRange IntegerRange, err error) {
+	return NewIntegerRange(keyString, big.NewInt(minimum), big.NewInt(maximum), maxTweakLength)
+}
+
+// Minimum returns the smallest integer in the range.
+func (integerRange *IntegerRange) Minimum() *big.Int {
+	return new(big.Int).Set(integerRange.minimum)
+}
+
+// Maximum returns the largest integer in the range.
+func (integerRange *IntegerRange) Maximum() *big.Int {
+	return new(big.Int).Set(integerRange.maximum)
+}
+
+// MinimumDomainSize returns the number of integers in the range.
+func (integerRange *IntegerRange) MinimumDomainSize() *big.Int {
+	return new(big.Int).Set(integerRange.size)
+}
+
+// Encrypt encrypts an integer in the range. It returns the encrypted integer,
+// which is also in the range, along with any error encountered during
+// encryption.
+// The plaintext argument should be the integer to encrypt.
+// The tweak argument should be the tweak to use in the encryption process.
+func (integerRange *IntegerRange) Encrypt(plaintext *big.Int, tweak []byte) (message *big.Int, err error) {
+	return integerRange.walk(plaintext, tweak, integerRange.ff1.EncryptNumerals)
+}
+
+// Decrypt decrypts an integer in the range. It returns the decrypted integer,
+// along with any error encountered during decryption.
+// The message argument should be the integer to decrypt.
+// The tweak argument should be the tweak to use in the decryption process.
+func (integerRange *IntegerRange) Decrypt(message *big.Int, tweak []byte) (plaintext *big.Int, err error) {
+	return integerRange.walk(message, tweak, integerRange.ff1.DecryptNumerals)
+}
+
+// EncryptInt64 encrypts an integer in the range like Encrypt, for ranges whose
+// bounds fit in an int64.
+func (integerRange *IntegerRange) EncryptInt64(plaintext int64, tweak []byte) (message int64, err error) {
+	result, err := integerRange.Encrypt(big.NewInt(plaintext), tweak)
+	if err != nil {
+		return 0, err
+	}
+	return result.Int64(), nil
+}
+
+// DecryptInt64 decrypts an integer in the range like Decrypt, for ranges whose
+// bounds fit in an int64.
+func (integerRange *IntegerRange) DecryptInt64(message int64, tweak []byte) (plaintext int64, err error) {
+	result, err := integerRange.Decrypt(big.NewInt(message), tweak)
+	if err != nil {
+		return 0, err
+	}
+	return result.Int64(), nil
+}
+
+// Utility Functions for IntegerRange
+
+// walk checks that x is in the range and cycle walks its offset from the start
+// of the range with cipher.
+func (integerRange *IntegerRange) walk(x *big.Int, tweak []byte, cipher func([]uint16, []byte) ([]uint16, error)) (*big.Int, error) {
+	if x.Cmp(integerRange.minimum) < 0 || x.Cmp(integerRange.maximum) > 0 {
+		return nil, errors.New("integer was outside the range")
+	}
+
+	offset := new(big.Int).Sub(x, integerRange.minimum)
+	offset, err := walkOffset(offset, integerRange.size, integerRange.bits, tweak, cipher)
+	if err != nil {
+		return nil, err
+	}
+	return offset.Add(offset, integerRange.minimum), nil
+}
+
+// offsetBits returns the length of the binary messages used to encrypt the
+// offsets below size, which is at least minRangeBits.
+func offsetBits(size *big.Int) int {
+	bits := new(big.Int).Sub(size, big.NewInt(1)).BitLen()
+	if bits < minRangeBits {
+		bits = minRangeBits
+	}
+	return bits
+}
+
+// walkOffset applies cipher to offset, written as a binary message of the
+// given number of bits, until the result is below size. Since cipher is a
+// permutation of the binary messages, this cycle walk is a permutation of the
+// offsets below size, and walking with the inverse of cipher undoes it.
+func walkOffset(offset, size *big.Int, bits int, tweak []byte, cipher func([]uint16, []byte) ([]uint16, error)) (*big.Int, error) {
+	for {
+		numerals, err := cipher(intToNumerals(offset, 2, bits), tweak)
+		if err != nil {
+			return nil, err
+		}
+		offset = numeralsToInt(numerals, 2)
+		if offset.Cmp(size) < 0 {
+			return offset, nil
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package fpe
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestIntegerRangeEncryptInt64(t *testing.T) {
+	t.Log("Testing IntegerRange encryption of an employee number... ")
+	integerRange, err := NewIntegerRangeInt64("2B7E151628AED2A6ABF7158809CF4F3C", 0, 2499999, 16)
+	assertNoError(t, err)
+	msg, err := integerRange.EncryptInt64(1234567, []byte{})
+	assertNoError(t, err)
+	if msg != 552314 {
+		t.Fatalf("Expected 552314, got %d", msg)
+	}
+}
+
+func TestIntegerRangeDecryptInt64(t *testing.T) {
+	t.Log("Testing IntegerRange decryption of an employee number... ")
+	integerRange, err := NewIntegerRangeInt64("2B7E151628AED2A6ABF7158809CF4F3C", 0, 2499999, 16)
+	assertNoError(t, err)
+	plaintext, err := integerRange.DecryptInt64(552314, []byte{})
+	assertNoError(t, err)
+	if plaintext != 1234567 {
+		t.Fatalf("Expected 1234567, got %d", plaintext)
+	}
+}
+
+func TestIntegerRangeSmallRange(t *testing.T) {
+	t.Log("Testing IntegerRange with a range smaller than the FF1 domain... ")
+	integerRange, err := NewIntegerRangeInt64("2B7E151628AED2A6ABF7158809CF4F3C", 1, 999, 16)
+	assertNoError(t, err)
+	msg, err := integerRange.EncryptInt64(42, []byte{})
+	assertNoError(t, err)
+	if msg != 198 {
+		t.Fatalf("Expected 198, got %d", msg)
+	}
+	tweak := []byte{0x39, 0x38, 0x37, 0x36, 0x35, 0x34, 0x33, 0x32, 0x31, 0x30}
+	msg, err = integerRange.EncryptInt64(42, tweak)
+	assertNoError(t, err)
+	if msg != 20 {
+		t.Fatalf("Expected 20, got %d", msg)
+	}
+}
+
+func TestIntegerRangeIsPermutation(t *testing.T) {
+	t.Log("Testing IntegerRange maps a small range onto itself... ")
+	integerRange, err := NewIntegerRangeInt64("2B7E151628AED2A6ABF7158809CF4F3C", -5, 20, 16)
+	assertNoError(t, err)
+	seen := make(map[int64]bool)
+	for x := int64(-5); x <= 20; x++ {
+		msg, err := integerRange.EncryptInt64(x, []byte{})
+		assertNoError(t, err)
+		if msg < -5 || msg > 20 || seen[msg] {
+			t.Fatalf("Expected a new integer in [-5, 20], got %d", msg)
+		}
+		seen[msg] = true
+		plaintext, err := integerRange.DecryptInt64(msg, []byte{})
+		assertNoError(t, err)
+		if plaintext != x {
+			t.Fatalf("Expected %d, got %d", x, plaintext)
+		}
+	}
+}
+
+func TestIntegerRangeBigInt(t *testing.T) {
+	t.Log("Testing IntegerRange with bounds larger than an int64... ")
+	bound, _ := new(big.Int).SetString("1000000000000000000000000000000", 10)
+	integerRange, err := NewIntegerRange("2B7E151628AED2A6ABF7158809CF4F3C", new(big.Int).Neg(bound), bound, 16)
+	assertNoError(t, err)
+	plaintext, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	msg, err := integerRange.Encrypt(plaintext, []byte{})
+	assertNoError(t, err)
+	assertExpectedResult(t, "563692659312337671335556425777", msg.String())
+	decrypted, err := integerRange.Decrypt(msg, []byte{})
+	assertNoError(t, err)
+	assertExpectedResult(t, plaintext.String(), decrypted.String())
+}
+
+func TestIntegerRangeOutsideRange(t *testing.T) {
+	t.Log("Testing IntegerRange encryption of an integer outside the range... ")
+	integerRange, err := NewIntegerRangeInt64("2B7E151628AED2A6ABF7158809CF4F3C", 1, 999, 16)
+	assertNoError(t, err)
+	_, err = integerRange.EncryptInt64(1000, []byte{})
+	assertError(t, err)
+	_, err = integerRange.EncryptInt64(0, []byte{})
+	assertError(t, err)
+}
+
+func TestNewIntegerRangeTooSmall(t *testing.T) {
+	t.Log("Testing NewIntegerRange with a single integer... ")
+	_, err := NewIntegerRangeInt64("2B7E151628AED2A6ABF7158809CF4F3C", 7, 7, 16)
+	assertError(t, err)
+}
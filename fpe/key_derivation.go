@@ -0,0 +1,52 @@
+package fpe
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// DeriveKey derives a key for one purpose from a master key with HKDF-SHA256
+// (RFC 5869), so that each purpose gets an independent key and learning one
+// of them reveals nothing about the master key or the others. It returns the
+// derived key in hexadecimal, the same length as the master key, along with
+// any error encountered in decoding the master key.
+// The masterKeyString argument should be the master AES key string in
+// hexadecimal, either 16, 24, or 32 bytes.
+// The info argument should name the purpose of the key, and must be different
+// for every key that should be independent.
+func DeriveKey(masterKeyString string, info []byte) (keyString string, err error) {
+	masterKey, err := hex.DecodeString(masterKeyString)
+	if err != nil {
+		return "", err
+	}
+	defer ZeroBytes(masterKey)
+	switch len(masterKey) {
+	case 16, 24, 32:
+	default:
+		return "", errors.New("master key must be 16, 24, or 32 bytes")
+	}
+
+	key, err := hkdfSHA256(masterKey, nil, info, len(masterKey))
+	if err != nil {
+		return "", err
+	}
+	defer ZeroBytes(key)
+	return hex.EncodeToString(key), nil
+}
+
+// Utility Functions for DeriveKey
+
+// hkdfSHA256 returns length bytes of HKDF-SHA256 output keying material for a
+// secret, salt and info. An empty salt is a block of zeros, as RFC 5869 sets
+// out. The length can be at most 255*32 bytes.
+func hkdfSHA256(secret, salt, info []byte, length int) ([]byte, error) {
+	key := make([]byte, length)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, info), key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
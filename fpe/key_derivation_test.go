@@ -0,0 +1,54 @@
+package fpe
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestHKDF(t *testing.T) {
+	t.Log("Testing HKDF-SHA256 against the RFC 5869 test vectors... ")
+	secret := bytes.Repeat([]byte{0x0b}, 22)
+	salt, _ := hex.DecodeString("000102030405060708090a0b0c")
+	info, _ := hex.DecodeString("f0f1f2f3f4f5f6f7f8f9")
+	key, err := hkdfSHA256(secret, salt, info, 42)
+	assertNoError(t, err)
+	assertExpectedResult(t, "3cb25f25faacd57a90434f64d0362f2a2d2d0a90cf1a5a4c5db02d56ecc4c5bf34007208d5b887185865",
+		hex.EncodeToString(key))
+	key, err = hkdfSHA256(secret, nil, nil, 42)
+	assertNoError(t, err)
+	assertExpectedResult(t, "8da4e775a563c18f715f802a063c5a31b8a11f5c5ee1879ec3454e5f3c738d2d9d201395faa4b61a96c8",
+		hex.EncodeToString(key))
+}
+
+func TestDeriveKey(t *testing.T) {
+	t.Log("Testing DeriveKey gives an independent key of the same length for each info... ")
+	ssn, err := DeriveKey("2B7E151628AED2A6ABF7158809CF4F3C", []byte("ssn"))
+	assertNoError(t, err)
+	again, err := DeriveKey("2B7E151628AED2A6ABF7158809CF4F3C", []byte("ssn"))
+	assertNoError(t, err)
+	assertExpectedResult(t, ssn, again)
+	phone, err := DeriveKey("2B7E151628AED2A6ABF7158809CF4F3C", []byte("phone"))
+	assertNoError(t, err)
+	if ssn == phone || len(ssn) != 32 || len(phone) != 32 {
+		t.Fatalf("Expected two distinct 16 byte keys, got %s and %s", ssn, phone)
+	}
+
+	long, err := DeriveKey("2B7E151628AED2A6ABF7158809CF4F3CEF4359D8D580AA4F7F036D6F04FC6A94", []byte("ssn"))
+	assertNoError(t, err)
+	if len(long) != 64 {
+		t.Fatalf("Expected a 32 byte key, got %s", long)
+	}
+	ff1, err := NewFF1(long, 10, 9, 9, 16)
+	assertNoError(t, err)
+	_, err = ff1.Encrypt("123456789", []byte{})
+	assertNoError(t, err)
+}
+
+func TestDeriveKeyInvalidMasterKey(t *testing.T) {
+	t.Log("Testing DeriveKey refuses invalid master keys... ")
+	_, err := DeriveKey("2B7E151628AED2A6XYZ7158809CF4F3C", []byte("ssn"))
+	assertError(t, err)
+	_, err = DeriveKey("2B7E151628AED2A6ABF7158809CF4F", []byte("ssn"))
+	assertError(t, err)
+}
@@ -0,0 +1,384 @@
+package fpe
+
+import (
+	"errors"
+	"math/big"
+	"regexp/syntax"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// firstLanguageCharacter and lastLanguageCharacter bound the characters a
+	// language is built from, which are the printable ASCII characters.
+	firstLanguageCharacter = ' '
+	lastLanguageCharacter  = '~'
+	// maxLanguageStates caps the number of DFA states a pattern compiles to.
+	maxLanguageStates = 10000
+)
+
+// The language type is a finite set of strings, recognised by a DFA, that can
+// be ranked: the strings are numbered from 0 in shortlex order, shortest first
+// and then in the order of their characters, so that rank and unrank map
+// between the strings and the integers below size.
+type language struct {
+	// transitions[s] lists the moves from state s to states that can still
+	// reach an accepting state, in the order of their characters.
+	transitions [][]languageTransition
+	accepting   []bool
+	// counts[s][k] is the number of strings of length k that lead from state s
+	// to an accepting state. counts[s] ends after the longest such string.
+	counts [][]*big.Int
+	size   *big.Int
+}
+
+// The languageTransition type is a move of the DFA from one state to the next
+// on a character.
+type languageTransition struct {
+	character rune
+	next      int
+}
+
+// newLanguage compiles a regular expression into a language of the printable
+// ASCII strings it matches in full. It returns an error if the pattern cannot
+// be parsed, uses assertions other than a leading ^ and trailing $, matches no
+// strings, or matches infinitely many.
+func newLanguage(pattern string) (*language, error) {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return nil, err
+	}
+	prog, err := syntax.Compile(trimAnchors(re).Simplify())
+	if err != nil {
+		return nil, err
+	}
+
+	lang := &language{}
+	if err = lang.buildStates(prog); err != nil {
+		return nil, err
+	}
+	if err = lang.countStrings(); err != nil {
+		return nil, err
+	}
+	if lang.size.Sign() == 0 {
+		return nil, errors.New("pattern did not match any printable ASCII strings")
+	}
+	return lang, nil
+}
+
+// rank returns the position of a string in the shortlex order of the
+// language, or an error if the language does not contain it.
+func (lang *language) rank(message string) (*big.Int, error) {
+	runes := []rune(message)
+	if len(runes) >= len(lang.counts[0]) {
+		return nil, errors.New("message did not match the pattern")
+	}
+
+	rank := big.NewInt(0)
+	for k := 0; k < len(runes); k++ {
+		rank.Add(rank, lang.count(0, k))
+	}
+
+	state := 0
+	for i, r := range runes {
+		remaining := len(runes) - i - 1
+		next := -1
+		for _, transition := range lang.transitions[state] {
+			if transition.character == r {
+				next = transition.next
+				break
+			}
+			rank.Add(rank, lang.count(transition.next, remaining))
+		}
+		if next < 0 {
+			return nil, errors.New("message did not match the pattern")
+		}
+		state = next
+	}
+	if !lang.accepting[state] {
+		return nil, errors.New("message did not match the pattern")
+	}
+	return rank, nil
+}
+
+// unrank returns the string at a position in the shortlex order of the
+// language. The rank must be less than the size of the language.
+func (lang *language) unrank(rank *big.Int) string {
+	x := new(big.Int).Set(rank)
+	length := 0
+	for ; length < len(lang.counts[0]); length++ {
+		count := lang.count(0, length)
+		if x.Cmp(count) < 0 {
+			break
+		}
+		x.Sub(x, count)
+	}
+
+	message := make([]rune, 0, length)
+	state := 0
+	for i := 0; i < length; i++ {
+		remaining := length - i - 1
+		for _, transition := range lang.transitions[state] {
+			count := lang.count(transition.next, remaining)
+			if x.Cmp(count) < 0 {
+				message = append(message, transition.character)
+				state = transition.next
+				break
+			}
+			x.Sub(x, count)
+		}
+	}
+	return string(message)
+}
+
+// Utility Functions for language
+
+// count returns the number of strings of length k that lead from state s to
+// an accepting state.
+func (lang *language) count(s, k int) *big.Int {
+	if k >= len(lang.counts[s]) {
+		return big.NewInt(0)
+	}
+	return lang.counts[s][k]
+}
+
+// buildStates builds the DFA for prog by subset construction over the
+// printable ASCII characters. The start state is state 0.
+func (lang *language) buildStates(prog *syntax.Prog) error {
+	stateIDs := make(map[string]int)
+	var stateSets [][]uint32
+
+	addState := func(pcs []uint32) (int, error) {
+		key := stateKey(pcs)
+		if id, found := stateIDs[key]; found {
+			return id, nil
+		}
+		if len(stateSets) >= maxLanguageStates {
+			return 0, errors.New("pattern compiled to too many states")
+		}
+		id := len(stateSets)
+		stateIDs[key] = id
+		stateSets = append(stateSets, pcs)
+		accepting := false
+		for _, pc := range pcs {
+			if prog.Inst[pc].Op == syntax.InstMatch {
+				accepting = true
+			}
+		}
+		lang.accepting = append(lang.accepting, accepting)
+		lang.transitions = append(lang.transitions, nil)
+		return id, nil
+	}
+
+	startSet, err := closure(prog, []uint32{uint32(prog.Start)})
+	if err != nil {
+		return err
+	}
+	if _, err = addState(startSet); err != nil {
+		return err
+	}
+
+	for s := 0; s < len(stateSets); s++ {
+		for r := rune(firstLanguageCharacter); r <= lastLanguageCharacter; r++ {
+			var targets []uint32
+			for _, pc := range stateSets[s] {
+				if matchesRune(&prog.Inst[pc], r) {
+					targets = append(targets, prog.Inst[pc].Out)
+				}
+			}
+			if len(targets) == 0 {
+				continue
+			}
+			nextSet, err := closure(prog, targets)
+			if err != nil {
+				return err
+			}
+			next, err := addState(nextSet)
+			if err != nil {
+				return err
+			}
+			lang.transitions[s] = append(lang.transitions[s], languageTransition{character: r, next: next})
+		}
+	}
+	return nil
+}
+
+// countStrings removes the transitions to states that cannot reach an
+// accepting state, checks that the language is finite, and fills in counts
+// and size.
+func (lang *language) countStrings() error {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	marks := make([]int, len(lang.transitions))
+	lang.counts = make([][]*big.Int, len(lang.transitions))
+
+	// visit computes the counts of state s after those of the states it leads
+	// to, and reports a cycle, which would make the language infinite.
+	var visit func(s int) error
+	visit = func(s int) error {
+		marks[s] = visiting
+		var live []languageTransition
+		for _, transition := range lang.transitions[s] {
+			switch marks[transition.next] {
+			case visiting:
+				return errors.New("pattern must match a finite set of strings")
+			case unvisited:
+				if err := visit(transition.next); err != nil {
+					return err
+				}
+			}
+			if len(lang.counts[transition.next]) > 0 {
+				live = append(live, transition)
+			}
+		}
+		lang.transitions[s] = live
+		marks[s] = visited
+
+		length := 0
+		if lang.accepting[s] {
+			length = 1
+		}
+		for _, transition := range live {
+			if len(lang.counts[transition.next])+1 > length {
+				length = len(lang.counts[transition.next]) + 1
+			}
+		}
+		counts := make([]*big.Int, length)
+		for k := range counts {
+			counts[k] = big.NewInt(0)
+		}
+		if lang.accepting[s] {
+			counts[0].SetInt64(1)
+		}
+		for _, transition := range live {
+			for k, count := range lang.counts[transition.next] {
+				counts[k+1].Add(counts[k+1], count)
+			}
+		}
+		lang.counts[s] = counts
+		return nil
+	}
+
+	// A cycle through states that cannot reach an accepting state does not
+	// make the language infinite, so such states are found first and left out
+	// of the search.
+	for s, reachesAccepting := range lang.coaccessible() {
+		if !reachesAccepting {
+			lang.transitions[s] = nil
+		}
+	}
+	if err := visit(0); err != nil {
+		return err
+	}
+
+	lang.size = big.NewInt(0)
+	for _, count := range lang.counts[0] {
+		lang.size.Add(lang.size, count)
+	}
+	return nil
+}
+
+// coaccessible returns whether each state can reach an accepting state.
+func (lang *language) coaccessible() []bool {
+	reaches := make([]bool, len(lang.transitions))
+	copy(reaches, lang.accepting)
+	for changed := true; changed; {
+		changed = false
+		for s, transitions := range lang.transitions {
+			if reaches[s] {
+				continue
+			}
+			for _, transition := range transitions {
+				if reaches[transition.next] {
+					reaches[s] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+	return reaches
+}
+
+// closure returns the sorted program counters of the rune and match
+// instructions reachable from pcs without consuming a character.
+func closure(prog *syntax.Prog, pcs []uint32) ([]uint32, error) {
+	seen := make(map[uint32]bool)
+	var result []uint32
+	stack := append([]uint32(nil), pcs...)
+	for len(stack) > 0 {
+		pc := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if seen[pc] {
+			continue
+		}
+		seen[pc] = true
+
+		inst := &prog.Inst[pc]
+		switch inst.Op {
+		case syntax.InstAlt, syntax.InstAltMatch:
+			stack = append(stack, inst.Out, inst.Arg)
+		case syntax.InstCapture, syntax.InstNop:
+			stack = append(stack, inst.Out)
+		case syntax.InstEmptyWidth:
+			return nil, errors.New("pattern may only use ^ and $ at its start and end")
+		case syntax.InstFail:
+		default:
+			result = append(result, pc)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+	return result, nil
+}
+
+// matchesRune reports whether a rune instruction consumes r.
+func matchesRune(inst *syntax.Inst, r rune) bool {
+	switch inst.Op {
+	case syntax.InstRune, syntax.InstRune1:
+		return inst.MatchRune(r)
+	case syntax.InstRuneAny:
+		return true
+	case syntax.InstRuneAnyNotNL:
+		return r != '\n'
+	}
+	return false
+}
+
+// stateKey returns a map key for a set of program counters.
+func stateKey(pcs []uint32) string {
+	var key strings.Builder
+	for _, pc := range pcs {
+		key.WriteString(strconv.FormatUint(uint64(pc), 10))
+		key.WriteByte(',')
+	}
+	return key.String()
+}
+
+// trimAnchors removes a leading ^ and trailing $ from a parsed pattern, since
+// the whole message is always matched.
+func trimAnchors(re *syntax.Regexp) *syntax.Regexp {
+	isStart := func(re *syntax.Regexp) bool {
+		return re.Op == syntax.OpBeginText || re.Op == syntax.OpBeginLine
+	}
+	isEnd := func(re *syntax.Regexp) bool {
+		return re.Op == syntax.OpEndText || re.Op == syntax.OpEndLine
+	}
+
+	if re.Op != syntax.OpConcat {
+		return re
+	}
+	subs := re.Sub
+	if len(subs) > 0 && isStart(subs[0]) {
+		subs = subs[1:]
+	}
+	if len(subs) > 0 && isEnd(subs[len(subs)-1]) {
+		subs = subs[:len(subs)-1]
+	}
+	trimmed := *re
+	trimmed.Sub = subs
+	return &trimmed
+}
@@ -0,0 +1,77 @@
+package fpe
+
+import (
+	"math/big"
+	"regexp"
+	"testing"
+)
+
+// enumerate returns every string of up to maxLength characters from
+// characters, which must be in ascending order, that fully matches pattern,
+// in shortlex order.
+func enumerate(pattern, characters string, maxLength int) []string {
+	re := regexp.MustCompile("^(?:" + pattern + ")$")
+	var matches []string
+	current := []string{""}
+	for length := 0; length <= maxLength; length++ {
+		var next []string
+		for _, prefix := range current {
+			if re.MatchString(prefix) {
+				matches = append(matches, prefix)
+			}
+			if length == maxLength {
+				continue
+			}
+			for _, c := range characters {
+				next = append(next, prefix+string(c))
+			}
+		}
+		current = next
+	}
+	return matches
+}
+
+func TestLanguageShortlexOrder(t *testing.T) {
+	t.Log("Testing language ranks match the shortlex order of regexp matches... ")
+	patterns := []string{"[ab]{1,2}|c", "^x?[0-3a]{2}(-[0-3])?$", "(?i)b[a-c]{0,2}", "1?2?3?"}
+	for _, pattern := range patterns {
+		lang, err := newLanguage(pattern)
+		assertNoError(t, err)
+		matches := enumerate(pattern, " -0123ABCabcx", 5)
+		assertExpectedResult(t, big.NewInt(int64(len(matches))).String(), lang.size.String())
+		for i, match := range matches {
+			rank, err := lang.rank(match)
+			assertNoError(t, err)
+			assertExpectedResult(t, big.NewInt(int64(i)).String(), rank.String())
+			assertExpectedResult(t, match, lang.unrank(rank))
+		}
+	}
+}
+
+func TestLanguageRankNonMatching(t *testing.T) {
+	t.Log("Testing language rank of strings that do not match... ")
+	lang, err := newLanguage("[A-Z]{1,3}[0-9]{2,6}")
+	assertNoError(t, err)
+	for _, message := range []string{"", "A1", "ABCD12", "AB1234567", "ab12", "AB12x"} {
+		_, err = lang.rank(message)
+		assertError(t, err)
+	}
+}
+
+func TestNewLanguageInfinite(t *testing.T) {
+	t.Log("Testing newLanguage with a pattern that matches infinitely many strings... ")
+	_, err := newLanguage("[A-Z]+[0-9]{2}")
+	assertError(t, err)
+}
+
+func TestNewLanguageWithAssertion(t *testing.T) {
+	t.Log("Testing newLanguage with a word boundary assertion... ")
+	_, err := newLanguage(`a\bb`)
+	assertError(t, err)
+}
+
+func TestNewLanguageEmpty(t *testing.T) {
+	t.Log("Testing newLanguage with a pattern that matches no printable ASCII... ")
+	_, err := newLanguage("é{2}")
+	assertError(t, err)
+}
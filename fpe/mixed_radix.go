@@ -0,0 +1,199 @@
+package fpe
+
+import (
+	"errors"
+	"math/big"
+	"regexp/syntax"
+)
+
+// The MixedRadix type allows for encryption and decryption of messages where
+// each position has its own alphabet, such as license plates of two letters,
+// three digits and a letter. The message is ranked as a mixed radix number,
+// the rank is encrypted with an IntegerRange over the product of the radixes,
+// and the result is unranked, so every position keeps its own alphabet. See
+// the NewMixedRadix, (mixedRadix *MixedRadix) Encrypt, and
+// (mixedRadix *MixedRadix) Decrypt functions for more detail.
+type MixedRadix struct {
+	alphabets    []Alphabet
+	integerRange IntegerRange
+}
+
+// NewMixedRadix returns a new MixedRadix struct for encrypting and decrypting
+// messages with an alphabet for each position. It will also return any
+// errors encountered in creating an AES key.
+// The keyString argument should be the AES key string in hexadecimal, either
+// 16, 24, or 32 bytes to select AES-128, AES-192, or AES-256.
+// The alphabets argument should be the alphabet of each position, in order,
+// each made with NewAlphabet; the zero Alphabet is refused. Messages have
+// exactly one character per alphabet.
+// The maxTweakLength argument should be the maximum length of tweaks, in bytes.
+func NewMixedRadix(keyString string, alphabets []Alphabet, maxTweakLength int) (mixedRadix MixedRadix, err error) {
+	if len(alphabets) == 0 {
+		return MixedRadix{}, errors.New("mixed radix needs at least one position")
+	}
+
+	size := big.NewInt(1)
+	for _, alphabet := range alphabets {
+		if alphabet.Radix() < 2 {
+			return MixedRadix{}, errors.New("mixed radix position had an alphabet not made with NewAlphabet")
+		}
+		size.Mul(size, big.NewInt(int64(alphabet.Radix())))
+	}
+
+	integerRange, err := NewIntegerRange(keyString, big.NewInt(0), size.Sub(size, big.NewInt(1)), maxTweakLength)
+	if err != nil {
+		return MixedRadix{}, err
+	}
+
+	return MixedRadix{
+		alphabets:    append([]Alphabet(nil), alphabets...),
+		integerRange: integerRange}, nil
+}
+
+// NewMixedRadixFromPattern returns a new MixedRadix struct like NewMixedRadix,
+// with the alphabets given as a pattern of character classes in the syntax of
+// the regexp package. Each class is one position, and its characters are the
+// alphabet in ascending order. Classes may be repeated a fixed number of
+// times, so "[A-Z]{2}[0-9]{3}[A-Z]" is two letters, three digits and a
+// letter. Literal characters are not positions; use a Template to keep them.
+func NewMixedRadixFromPattern(keyString, pattern string, maxTweakLength int) (mixedRadix MixedRadix, err error) {
+	alphabets, err := patternAlphabets(pattern)
+	if err != nil {
+		return MixedRadix{}, err
+	}
+	return NewMixedRadix(keyString, alphabets, maxTweakLength)
+}
+
+// Size returns the number of messages, which is the product of the radixes of
+// the positions.
+func (mixedRadix *MixedRadix) Size() *big.Int {
+	return new(big.Int).Add(mixedRadix.integerRange.Maximum(), big.NewInt(1))
+}
+
+// MinimumDomainSize returns the number of messages, which is the product of
+// the radixes of the positions.
+func (mixedRadix *MixedRadix) MinimumDomainSize() *big.Int {
+	return mixedRadix.Size()
+}
+
+// Encrypt encrypts a message with a character from the alphabet of each
+// position. It returns the encrypted message, which also has a character
+// from the alphabet of each position, along with any error encountered
+// during encryption.
+// The plaintext argument should be the message to encrypt.
+// The tweak argument should be the tweak to use in the encryption process.
+func (mixedRadix *MixedRadix) Encrypt(plaintext string, tweak []byte) (message string, err error) {
+	rank, err := mixedRadix.rank(plaintext)
+	if err != nil {
+		return message, err
+	}
+	rank, err = mixedRadix.integerRange.Encrypt(rank, tweak)
+	if err != nil {
+		return message, err
+	}
+	return mixedRadix.unrank(rank), nil
+}
+
+// Decrypt decrypts a message with a character from the alphabet of each
+// position. It returns the decrypted message, along with any error
+// encountered during decryption.
+// The message argument should be the message to decrypt.
+// The tweak argument should be the tweak to use in the decryption process.
+func (mixedRadix *MixedRadix) Decrypt(message string, tweak []byte) (plaintext string, err error) {
+	rank, err := mixedRadix.rank(message)
+	if err != nil {
+		return plaintext, err
+	}
+	rank, err = mixedRadix.integerRange.Decrypt(rank, tweak)
+	if err != nil {
+		return plaintext, err
+	}
+	return mixedRadix.unrank(rank), nil
+}
+
+// Utility Functions for MixedRadix
+
+// rank returns the mixed radix number written by a message, with the first
+// position most significant.
+func (mixedRadix *MixedRadix) rank(message string) (*big.Int, error) {
+	runes := []rune(message)
+	if len(runes) != len(mixedRadix.alphabets) {
+		return nil, errors.New("message length did not match the number of positions")
+	}
+
+	rank := big.NewInt(0)
+	radix := big.NewInt(0)
+	numeral := big.NewInt(0)
+	for i, r := range runes {
+		n, found := mixedRadix.alphabets[i].numerals[r]
+		if !found {
+			return nil, errors.New("message contained a character that is not in the alphabet of its position")
+		}
+		rank.Mul(rank, radix.SetInt64(int64(mixedRadix.alphabets[i].Radix())))
+		rank.Add(rank, numeral.SetInt64(int64(n)))
+	}
+	return rank, nil
+}
+
+// unrank returns the message that writes a mixed radix number, which must be
+// less than the size.
+func (mixedRadix *MixedRadix) unrank(rank *big.Int) string {
+	quotient := new(big.Int).Set(rank)
+	radix := big.NewInt(0)
+	remainder := big.NewInt(0)
+	message := make([]rune, len(mixedRadix.alphabets))
+	for i := len(mixedRadix.alphabets) - 1; i >= 0; i-- {
+		alphabet := mixedRadix.alphabets[i]
+		quotient.QuoRem(quotient, radix.SetInt64(int64(alphabet.Radix())), remainder)
+		message[i] = alphabet.characters[remainder.Int64()]
+	}
+	return string(message)
+}
+
+// patternAlphabets returns the alphabet of each character class in a pattern
+// made only of character classes with fixed repetition.
+func patternAlphabets(pattern string) ([]Alphabet, error) {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return nil, err
+	}
+
+	var alphabets []Alphabet
+	for _, position := range flattenConcat(re.Simplify()) {
+		if position.Op != syntax.OpCharClass {
+			return nil, errors.New("mixed radix pattern may only contain character classes with fixed repetition")
+		}
+
+		var characters []rune
+		for i := 0; i+1 < len(position.Rune); i += 2 {
+			for r := position.Rune[i]; r <= position.Rune[i+1]; r++ {
+				if len(characters) >= 65536 {
+					return nil, errors.New("mixed radix pattern had a character class with more than 65536 characters")
+				}
+				characters = append(characters, r)
+			}
+		}
+		alphabet, err := NewAlphabet(string(characters))
+		if err != nil {
+			return nil, err
+		}
+		alphabets = append(alphabets, alphabet)
+	}
+	return alphabets, nil
+}
+
+// flattenConcat returns the parts of a parsed pattern that are concatenated,
+// looking inside nested concatenations and groups.
+func flattenConcat(re *syntax.Regexp) []*syntax.Regexp {
+	switch re.Op {
+	case syntax.OpConcat:
+		var parts []*syntax.Regexp
+		for _, sub := range re.Sub {
+			parts = append(parts, flattenConcat(sub)...)
+		}
+		return parts
+	case syntax.OpCapture:
+		return flattenConcat(re.Sub[0])
+	}
+	return []*syntax.Regexp{re}
+}
@@ -0,0 +1,116 @@
+package fpe
+
+import (
+	"math/big"
+	"testing"
+)
+
+// newTestAlphabets returns an alphabet for each of the given strings.
+func newTestAlphabets(t *testing.T, characters ...string) []Alphabet {
+	alphabets := make([]Alphabet, len(characters))
+	for i, c := range characters {
+		alphabet, err := NewAlphabet(c)
+		assertNoError(t, err)
+		alphabets[i] = alphabet
+	}
+	return alphabets
+}
+
+// assertMixedRadixPermutation encrypts every message of a mixed radix domain
+// and checks that the results are distinct messages of the domain that
+// decrypt back.
+func assertMixedRadixPermutation(t *testing.T, mixedRadix MixedRadix, tweak []byte) {
+	size := int(mixedRadix.Size().Int64())
+	seen := make(map[string]bool, size)
+	for i := 0; i < size; i++ {
+		plaintext := mixedRadix.unrank(big.NewInt(int64(i)))
+		msg, err := mixedRadix.Encrypt(plaintext, tweak)
+		assertNoError(t, err)
+		if _, err := mixedRadix.rank(msg); err != nil || seen[msg] {
+			t.Fatalf("Expected a new message of the domain, got %s", msg)
+		}
+		seen[msg] = true
+		decrypted, err := mixedRadix.Decrypt(msg, tweak)
+		assertNoError(t, err)
+		assertExpectedResult(t, plaintext, decrypted)
+	}
+	if len(seen) != size {
+		t.Fatalf("Expected %d messages, got %d", size, len(seen))
+	}
+}
+
+func TestMixedRadixEncrypt(t *testing.T) {
+	t.Log("Testing MixedRadix encryption of a license plate... ")
+	mixedRadix, err := NewMixedRadixFromPattern("2B7E151628AED2A6ABF7158809CF4F3C", "[A-Z]{2}[0-9]{3}[A-Z]", 16)
+	assertNoError(t, err)
+	assertExpectedResult(t, "17576000", mixedRadix.Size().String())
+	msg, err := mixedRadix.Encrypt("UH123A", []byte{})
+	assertNoError(t, err)
+	assertExpectedResult(t, "XC014E", msg)
+}
+
+func TestMixedRadixDecrypt(t *testing.T) {
+	t.Log("Testing MixedRadix decryption of a license plate... ")
+	mixedRadix, err := NewMixedRadixFromPattern("2B7E151628AED2A6ABF7158809CF4F3C", "[A-Z]{2}[0-9]{3}[A-Z]", 16)
+	assertNoError(t, err)
+	plaintext, err := mixedRadix.Decrypt("XC014E", []byte{})
+	assertNoError(t, err)
+	assertExpectedResult(t, "UH123A", plaintext)
+}
+
+func TestMixedRadixWithTweak(t *testing.T) {
+	t.Log("Testing MixedRadix encryption of a license plate with a tweak... ")
+	alphabets := newTestAlphabets(t, "ABCDEFGHIJKLMNOPQRSTUVWXYZ", "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+		"0123456789", "0123456789", "0123456789", "ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+	mixedRadix, err := NewMixedRadix("2B7E151628AED2A6ABF7158809CF4F3C", alphabets, 16)
+	assertNoError(t, err)
+	tweak := []byte{0x39, 0x38, 0x37, 0x36, 0x35, 0x34, 0x33, 0x32, 0x31, 0x30}
+	msg, err := mixedRadix.Encrypt("AA000A", tweak)
+	assertNoError(t, err)
+	assertExpectedResult(t, "UV794H", msg)
+}
+
+func TestMixedRadixSmallDomain(t *testing.T) {
+	t.Log("Testing MixedRadix round trips every message of a small domain... ")
+	alphabets := newTestAlphabets(t, "AB", "012", "XYZ")
+	mixedRadix, err := NewMixedRadix("2B7E151628AED2A6ABF7158809CF4F3C", alphabets, 16)
+	assertNoError(t, err)
+	assertMixedRadixPermutation(t, mixedRadix, []byte{})
+	assertMixedRadixPermutation(t, mixedRadix, []byte{0x01, 0x02, 0x03})
+}
+
+func TestMixedRadixLetterDigitDomain(t *testing.T) {
+	t.Log("Testing MixedRadix round trips every message of a letter and digit domain... ")
+	mixedRadix, err := NewMixedRadixFromPattern("2B7E151628AED2A6ABF7158809CF4F3C", "[A-F][0-9]{2}[xyz]", 16)
+	assertNoError(t, err)
+	assertExpectedResult(t, "1800", mixedRadix.Size().String())
+	assertMixedRadixPermutation(t, mixedRadix, []byte{})
+}
+
+func TestMixedRadixWrongCharacter(t *testing.T) {
+	t.Log("Testing MixedRadix encryption of a digit in a letter position... ")
+	mixedRadix, err := NewMixedRadixFromPattern("2B7E151628AED2A6ABF7158809CF4F3C", "[A-Z]{2}[0-9]{3}[A-Z]", 16)
+	assertNoError(t, err)
+	_, err = mixedRadix.Encrypt("U1123A", []byte{})
+	assertError(t, err)
+	_, err = mixedRadix.Encrypt("UH123", []byte{})
+	assertError(t, err)
+}
+
+func TestNewMixedRadixWithZeroAlphabet(t *testing.T) {
+	t.Log("Testing NewMixedRadix with a zero Alphabet... ")
+	alphabets := append(newTestAlphabets(t, "AB", "012"), Alphabet{})
+	_, err := NewMixedRadix("2B7E151628AED2A6ABF7158809CF4F3C", alphabets, 16)
+	assertError(t, err)
+	assertExpectedResult(t, "mixed radix position had an alphabet not made with NewAlphabet", err.Error())
+	_, err = NewMixedRadix("2B7E151628AED2A6ABF7158809CF4F3C", []Alphabet{}, 16)
+	assertError(t, err)
+}
+
+func TestNewMixedRadixFromPatternWithLiteral(t *testing.T) {
+	t.Log("Testing NewMixedRadixFromPattern with a literal character... ")
+	_, err := NewMixedRadixFromPattern("2B7E151628AED2A6ABF7158809CF4F3C", "[A-Z]{2}-[0-9]{3}", 16)
+	assertError(t, err)
+	_, err = NewMixedRadixFromPattern("2B7E151628AED2A6ABF7158809CF4F3C", "[A-Z]+", 16)
+	assertError(t, err)
+}
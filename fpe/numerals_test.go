@@ -0,0 +1,102 @@
+package fpe
+
+import (
+	"testing"
+)
+
+func TestFF1EncryptNumeralsRadix10(t *testing.T) {
+	t.Log("Testing FF1 numeral encryption against the radix 10 sample... ")
+	ff1, err := NewFF1("2B7E151628AED2A6ABF7158809CF4F3C", 10, 2, 20, 16)
+	assertNoError(t, err)
+	msg, err := ff1.EncryptNumerals([]uint16{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, []byte{})
+	assertNoError(t, err)
+	assertExpectedNumerals(t, []uint16{2, 4, 3, 3, 4, 7, 7, 4, 8, 4}, msg)
+}
+
+func TestFF1EncryptNumeralsRadix256(t *testing.T) {
+	t.Log("Testing FF1 numeral encryption with radix 256... ")
+	ff1, err := NewFF1("2B7E151628AED2A6ABF7158809CF4F3C", 256, 2, 32, 16)
+	assertNoError(t, err)
+	msg, err := ff1.EncryptNumerals([]uint16{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}, []byte{})
+	assertNoError(t, err)
+	assertExpectedNumerals(t, []uint16{87, 24, 216, 59, 201, 206, 85, 71, 250, 109, 161, 150, 36, 159, 157, 158}, msg)
+}
+
+func TestFF1DecryptNumeralsRadix256(t *testing.T) {
+	t.Log("Testing FF1 numeral decryption with radix 256... ")
+	ff1, err := NewFF1("2B7E151628AED2A6ABF7158809CF4F3C", 256, 2, 32, 16)
+	assertNoError(t, err)
+	plaintext, err := ff1.DecryptNumerals([]uint16{87, 24, 216, 59, 201, 206, 85, 71, 250, 109, 161, 150, 36, 159, 157, 158}, []byte{})
+	assertNoError(t, err)
+	assertExpectedNumerals(t, []uint16{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}, plaintext)
+}
+
+func TestFF1EncryptNumeralsRadix65536(t *testing.T) {
+	t.Log("Testing FF1 numeral encryption with radix 2^16... ")
+	ff1, err := NewFF1("2B7E151628AED2A6ABF7158809CF4F3C", 65536, 2, 32, 16)
+	assertNoError(t, err)
+	msg, err := ff1.EncryptNumerals([]uint16{0, 1, 2, 3, 4, 5, 6, 7}, []byte{0x01, 0x02, 0x03, 0x04, 0x05})
+	assertNoError(t, err)
+	assertExpectedNumerals(t, []uint16{48297, 33228, 44601, 56785, 58060, 64180, 24075, 53146}, msg)
+}
+
+func TestFF1DecryptNumeralsRadix65536(t *testing.T) {
+	t.Log("Testing FF1 numeral decryption with radix 2^16... ")
+	ff1, err := NewFF1("2B7E151628AED2A6ABF7158809CF4F3C", 65536, 2, 32, 16)
+	assertNoError(t, err)
+	plaintext, err := ff1.DecryptNumerals([]uint16{48297, 33228, 44601, 56785, 58060, 64180, 24075, 53146}, []byte{0x01, 0x02, 0x03, 0x04, 0x05})
+	assertNoError(t, err)
+	assertExpectedNumerals(t, []uint16{0, 1, 2, 3, 4, 5, 6, 7}, plaintext)
+}
+
+func TestFF1EncryptNumeralsInvalidNumeral(t *testing.T) {
+	t.Log("Testing FF1 numeral encryption with a numeral that is not less than the radix... ")
+	ff1, err := NewFF1("2B7E151628AED2A6ABF7158809CF4F3C", 62, 2, 32, 16)
+	assertNoError(t, err)
+	_, err = ff1.EncryptNumerals([]uint16{0, 1, 2, 62}, []byte{})
+	assertError(t, err)
+}
+
+func TestFF1EncryptRadix94WithoutAlphabet(t *testing.T) {
+	t.Log("Testing FF1 string encryption with radix 94 and no alphabet... ")
+	ff1, err := NewFF1("2B7E151628AED2A6ABF7158809CF4F3C", 94, 2, 32, 16)
+	assertNoError(t, err)
+	_, err = ff1.Encrypt("0123456789", []byte{})
+	assertError(t, err)
+}
+
+func TestFF3EncryptNumeralsRadix10(t *testing.T) {
+	t.Log("Testing FF3 numeral encryption against the radix 10 sample... ")
+	ff3, err := NewFF3("EF4359D8D580AA4F7F036D6F04FC6A94", 10, 2, 20)
+	assertNoError(t, err)
+	msg, err := ff3.EncryptNumerals([]uint16{8, 9, 0, 1, 2, 1, 2, 3, 4, 5, 6, 7, 8, 9, 0, 0, 0, 0}, []byte{0xD8, 0xE7, 0x92, 0x0A, 0xFA, 0x33, 0x0A, 0x73})
+	assertNoError(t, err)
+	assertExpectedNumerals(t, []uint16{7, 5, 0, 9, 1, 8, 8, 1, 4, 0, 5, 8, 6, 5, 4, 6, 0, 7}, msg)
+}
+
+func TestFF3NumeralsRoundTripRadix94(t *testing.T) {
+	t.Log("Testing FF3 numeral round trip with radix 94... ")
+	ff3, err := NewFF3("EF4359D8D580AA4F7F036D6F04FC6A94", 94, 2, 28)
+	assertNoError(t, err)
+	plaintext := []uint16{93, 0, 17, 42, 64, 5, 88, 31, 2, 77, 50, 13}
+	tweak := []byte{0xD8, 0xE7, 0x92, 0x0A, 0xFA, 0x33, 0x0A, 0x73}
+	msg, err := ff3.EncryptNumerals(plaintext, tweak)
+	assertNoError(t, err)
+	assertNoError(t, checkNumerals(msg, 94))
+	decrypted, err := ff3.DecryptNumerals(msg, tweak)
+	assertNoError(t, err)
+	assertExpectedNumerals(t, plaintext, decrypted)
+}
+
+func TestFF31NumeralsRoundTripRadix256(t *testing.T) {
+	t.Log("Testing FF3-1 numeral round trip with radix 256... ")
+	ff31, err := NewFF31("EF4359D8D580AA4F7F036D6F04FC6A94", 256, 2, 22)
+	assertNoError(t, err)
+	plaintext := []uint16{255, 0, 128, 64, 32, 16, 8, 4, 2, 1}
+	tweak := []byte{0xD8, 0xE7, 0x92, 0x0A, 0xFA, 0x33, 0x0A}
+	msg, err := ff31.EncryptNumerals(plaintext, tweak)
+	assertNoError(t, err)
+	decrypted, err := ff31.DecryptNumerals(msg, tweak)
+	assertNoError(t, err)
+	assertExpectedNumerals(t, plaintext, decrypted)
+}
@@ -0,0 +1,100 @@
+package fpe
+
+import (
+	"errors"
+	"math/big"
+)
+
+// The RegexFormat type allows for encryption and decryption of the strings
+// matched by a regular expression, such as "[A-Z]{1,3}[0-9]{2,6}", so that
+// every matching string encrypts to another matching string. The strings are
+// ranked in shortlex order, the rank is encrypted with an IntegerRange over
+// the number of strings, and the result is unranked back into a string. See
+// the NewRegexFormat, (regexFormat *RegexFormat) Encrypt, and
+// (regexFormat *RegexFormat) Decrypt functions for more detail.
+type RegexFormat struct {
+	pattern      string
+	language     *language
+	integerRange IntegerRange
+}
+
+// NewRegexFormat returns a new RegexFormat struct for encrypting and
+// decrypting the strings matched by a regular expression. It will also return
+// any errors encountered in compiling the pattern or creating an AES key.
+// The keyString argument should be the AES key string in hexadecimal, either
+// 16, 24, or 32 bytes to select AES-128, AES-192, or AES-256.
+// The pattern argument should be a regular expression in the syntax of the
+// regexp package. Messages must match the whole pattern, so ^ and $ may only
+// appear at its start and end. Only printable ASCII characters are matched,
+// and the pattern must match a finite number of strings, at least 2, so it
+// cannot use * or + or unbounded repetition.
+// The maxTweakLength argument should be the maximum length of tweaks, in bytes.
+func NewRegexFormat(keyString, pattern string, maxTweakLength int) (regexFormat RegexFormat, err error) {
+	lang, err := newLanguage(pattern)
+	if err != nil {
+		return RegexFormat{}, err
+	}
+	if lang.size.Cmp(big.NewInt(2)) < 0 {
+		return RegexFormat{}, errors.New("pattern must match at least 2 strings")
+	}
+
+	maximum := new(big.Int).Sub(lang.size, big.NewInt(1))
+	integerRange, err := NewIntegerRange(keyString, big.NewInt(0), maximum, maxTweakLength)
+	if err != nil {
+		return RegexFormat{}, err
+	}
+
+	return RegexFormat{
+		pattern:      pattern,
+		language:     lang,
+		integerRange: integerRange}, nil
+}
+
+// String returns the pattern the RegexFormat was made with.
+func (regexFormat *RegexFormat) String() string {
+	return regexFormat.pattern
+}
+
+// Size returns the number of strings the pattern matches.
+func (regexFormat *RegexFormat) Size() *big.Int {
+	return new(big.Int).Set(regexFormat.language.size)
+}
+
+// MinimumDomainSize returns the number of strings the pattern matches, since
+// they are all encrypted within one domain.
+func (regexFormat *RegexFormat) MinimumDomainSize() *big.Int {
+	return regexFormat.Size()
+}
+
+// Encrypt encrypts a message that matches the pattern. It returns the
+// encrypted message, which also matches the pattern, along with any error
+// encountered during encryption.
+// The plaintext argument should be the message to encrypt.
+// The tweak argument should be the tweak to use in the encryption process.
+func (regexFormat *RegexFormat) Encrypt(plaintext string, tweak []byte) (message string, err error) {
+	rank, err := regexFormat.language.rank(plaintext)
+	if err != nil {
+		return message, err
+	}
+	rank, err = regexFormat.integerRange.Encrypt(rank, tweak)
+	if err != nil {
+		return message, err
+	}
+	return regexFormat.language.unrank(rank), nil
+}
+
+// Decrypt decrypts a message that matches the pattern. It returns the
+// decrypted message, along with any error encountered during decryption.
+// The message argument should be the message to decrypt.
+// The tweak argument should be the tweak to use in the decryption process.
+func (regexFormat *RegexFormat) Decrypt(message string, tweak []byte) (plaintext string, err error) {
+	rank, err := regexFormat.language.rank(message)
+	if err != nil {
+		return plaintext, err
+	}
+	rank, err = regexFormat.integerRange.Decrypt(rank, tweak)
+	if err != nil {
+		return plaintext, err
+	}
+	return regexFormat.language.unrank(rank), nil
+}
@@ -0,0 +1,83 @@
+package fpe
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestRegexFormatEncrypt(t *testing.T) {
+	t.Log("Testing RegexFormat encryption of letters followed by digits... ")
+	regexFormat, err := NewRegexFormat("2B7E151628AED2A6ABF7158809CF4F3C", "[A-Z]{1,3}[0-9]{2,6}", 16)
+	assertNoError(t, err)
+	assertExpectedResult(t, "20308685800", regexFormat.Size().String())
+	msg, err := regexFormat.Encrypt("UH0012", []byte{})
+	assertNoError(t, err)
+	assertExpectedResult(t, "ZZ454201", msg)
+}
+
+func TestRegexFormatDecrypt(t *testing.T) {
+	t.Log("Testing RegexFormat decryption of letters followed by digits... ")
+	regexFormat, err := NewRegexFormat("2B7E151628AED2A6ABF7158809CF4F3C", "[A-Z]{1,3}[0-9]{2,6}", 16)
+	assertNoError(t, err)
+	plaintext, err := regexFormat.Decrypt("ZZ454201", []byte{})
+	assertNoError(t, err)
+	assertExpectedResult(t, "UH0012", plaintext)
+}
+
+func TestRegexFormatWithTweak(t *testing.T) {
+	t.Log("Testing RegexFormat round trip with a tweak... ")
+	regexFormat, err := NewRegexFormat("2B7E151628AED2A6ABF7158809CF4F3C", "^[A-Z]{1,3}[0-9]{2,6}$", 16)
+	assertNoError(t, err)
+	tweak := []byte{0x39, 0x38, 0x37, 0x36, 0x35, 0x34, 0x33, 0x32, 0x31, 0x30}
+	msg, err := regexFormat.Encrypt("A00", tweak)
+	assertNoError(t, err)
+	assertExpectedResult(t, "HMZ968356", msg)
+	plaintext, err := regexFormat.Decrypt(msg, tweak)
+	assertNoError(t, err)
+	assertExpectedResult(t, "A00", plaintext)
+}
+
+func TestRegexFormatSmallLanguage(t *testing.T) {
+	t.Log("Testing RegexFormat permutes a small language... ")
+	regexFormat, err := NewRegexFormat("2B7E151628AED2A6ABF7158809CF4F3C", "[ab]{1,2}|c", 16)
+	assertNoError(t, err)
+	plaintexts := []string{"a", "b", "c", "aa", "ab", "ba", "bb"}
+	expected := []string{"ab", "b", "aa", "ba", "bb", "a", "c"}
+	for i, plaintext := range plaintexts {
+		msg, err := regexFormat.Encrypt(plaintext, []byte{})
+		assertNoError(t, err)
+		assertExpectedResult(t, expected[i], msg)
+	}
+}
+
+func TestRegexFormatStaysInPattern(t *testing.T) {
+	t.Log("Testing RegexFormat output matches the pattern... ")
+	pattern := `[A-Z]{2}-[0-9]{3,4}( ?[a-z])?`
+	regexFormat, err := NewRegexFormat("2B7E151628AED2A6ABF7158809CF4F3C", pattern, 16)
+	assertNoError(t, err)
+	re := regexp.MustCompile("^(?:" + pattern + ")$")
+	for _, plaintext := range []string{"UH-001", "AB-1234 x", "ZZ-9999z", "QA-000"} {
+		msg, err := regexFormat.Encrypt(plaintext, []byte{})
+		assertNoError(t, err)
+		if !re.MatchString(msg) {
+			t.Fatalf("Expected %s to match %s", msg, pattern)
+		}
+		decrypted, err := regexFormat.Decrypt(msg, []byte{})
+		assertNoError(t, err)
+		assertExpectedResult(t, plaintext, decrypted)
+	}
+}
+
+func TestRegexFormatNonMatching(t *testing.T) {
+	t.Log("Testing RegexFormat encryption of a message that does not match... ")
+	regexFormat, err := NewRegexFormat("2B7E151628AED2A6ABF7158809CF4F3C", "[A-Z]{1,3}[0-9]{2,6}", 16)
+	assertNoError(t, err)
+	_, err = regexFormat.Encrypt("UH-0012", []byte{})
+	assertError(t, err)
+}
+
+func TestNewRegexFormatSingleString(t *testing.T) {
+	t.Log("Testing NewRegexFormat with a pattern that matches one string... ")
+	_, err := NewRegexFormat("2B7E151628AED2A6ABF7158809CF4F3C", "abc", 16)
+	assertError(t, err)
+}
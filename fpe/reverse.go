@@ -1,12 +1,13 @@
 package fpe
 
-// reverse takes a string as input and returns its reverse.
-func reverse(s string) string {
-	r := []rune(s)
-	for i, j := 0, len(r)-1; i < len(r)/2; i, j = i+1, j-1 {
-		r[i], r[j] = r[j], r[i]
+// reverseNumerals takes a slice of numerals as input and returns a new slice
+// with the order of the numerals reversed.
+func reverseNumerals(numerals []uint16) []uint16 {
+	reverse := make([]uint16, len(numerals))
+	for i, j := 0, len(numerals)-1; j >= 0; i, j = i+1, j-1 {
+		reverse[i] = numerals[j]
 	}
-	return string(r)
+	return reverse
 }
 
 // reverse takes a byte slice as input and returns a new byte slice with the
@@ -0,0 +1,370 @@
+package fpe
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"sync"
+)
+
+const (
+	// maxSmallDomainSize is the largest number of messages of one length a
+	// SmallDomain will build a table for.
+	maxSmallDomainSize = 1 << 16
+	// maxSmallDomainTables is the most tables a SmallDomain caches before it
+	// starts over, which bounds its memory when tweaks vary.
+	maxSmallDomainTables = 256
+)
+
+// The Warning type describes a security trade-off made by a constructor that
+// otherwise succeeded. It should be logged or shown to whoever chose the
+// configuration.
+type Warning string
+
+// SmallDomainWarning is returned by NewSmallDomain and
+// NewSmallDomainWithAlphabet.
+const SmallDomainWarning Warning = "small domain mode: messages are encrypted " +
+	"with a keyed shuffle of every message of their length instead of FF1 or " +
+	"FF3-1. The shuffle is a secure permutation, but with at most 65536 " +
+	"messages of a length an attacker can learn much of the mapping from a " +
+	"few known plaintexts or guess a plaintext outright, so it only hides " +
+	"values whose domain is already small. Use a distinct tweak per context " +
+	"where you can."
+
+// The SmallDomain type allows for encryption and decryption of messages whose
+// domain is too small for FF1 and FF3-1, which need radix^minlen >= 100. Each
+// message length and tweak gets a keyed shuffle of every message of that
+// length, built with a Knuth shuffle driven by AES and cached. See the
+// NewSmallDomain, (smallDomain *SmallDomain) Encrypt, and
+// (smallDomain *SmallDomain) Decrypt functions for more detail.
+type SmallDomain struct {
+	cipher           *destroyableBlock
+	alphabet         *Alphabet
+	radix            int
+	minMessageLength int
+	maxMessageLength int
+	maxTweakLength   int
+	cache            *smallDomainCache
+}
+
+// The smallDomainTable type is the keyed shuffle of the messages of one length
+// for one tweak, with each message given as the number its numerals write.
+type smallDomainTable struct {
+	forward []uint32
+	inverse []uint32
+}
+
+// The smallDomainCache type holds the tables built by a SmallDomain, keyed by
+// message length and tweak. It is shared by copies of the SmallDomain.
+type smallDomainCache struct {
+	mutex  sync.RWMutex
+	tables map[string]*smallDomainTable
+}
+
+// NewSmallDomain returns a new SmallDomain struct for encrypting and
+// decrypting messages from a small domain, along with SmallDomainWarning,
+// which explains its security trade-off. It will also return any errors
+// encountered in creating an AES key.
+// The keyString argument should be the AES key string in hexadecimal, either
+// 16, 24, or 32 bytes to select AES-128, AES-192, or AES-256.
+// The radix argument should be the number of characters in the alphabet that
+// will be used. Messages are written with the first radix characters of
+// 0-9a-zA-Z, as with NewFF1.
+// The minMessageLength and maxMessageLength arguments should be the minimum
+// and maximum message lengths that will be allowed. The minimum must be at
+// least 1, and radix^maxMessageLength can be at most 65536.
+// The maxTweakLength argument should be the maximum length of tweaks, in bytes.
+func NewSmallDomain(keyString string, radix, minMessageLength, maxMessageLength, maxTweakLength int) (smallDomain SmallDomain, warning Warning, err error) {
+	key, err := hex.DecodeString(keyString)
+	if err != nil {
+		return SmallDomain{}, "", err
+	}
+	defer ZeroBytes(key)
+	cph, err := aes.NewCipher(key)
+	if err != nil {
+		return SmallDomain{}, "", err
+	}
+
+	if radix < 2 || radix > maxSmallDomainSize {
+		return SmallDomain{}, "", errors.New("radix must be in [2..2^16]")
+	}
+	if minMessageLength < 1 || minMessageLength > maxMessageLength {
+		return SmallDomain{}, "", errors.New("1 <= minlen <= maxlen")
+	}
+	size := 1
+	for i := 0; i < maxMessageLength; i++ {
+		size *= radix
+		if size > maxSmallDomainSize {
+			return SmallDomain{}, "", errors.New("radix^maxlen <= 2^16")
+		}
+	}
+
+	return SmallDomain{
+		cipher:           newDestroyableBlock(cph, key),
+		alphabet:         defaultAlphabet(radix),
+		radix:            radix,
+		minMessageLength: minMessageLength,
+		maxMessageLength: maxMessageLength,
+		maxTweakLength:   maxTweakLength,
+		cache:            &smallDomainCache{tables: make(map[string]*smallDomainTable)}}, SmallDomainWarning, nil
+}
+
+// NewSmallDomainWithAlphabet returns a new SmallDomain struct like
+// NewSmallDomain, except that messages are written using the characters of
+// the given alphabet instead of the first radix characters of 0-9a-zA-Z.
+func NewSmallDomainWithAlphabet(keyString string, alphabet Alphabet, minMessageLength, maxMessageLength, maxTweakLength int) (smallDomain SmallDomain, warning Warning, err error) {
+	smallDomain, warning, err = NewSmallDomain(keyString, alphabet.Radix(), minMessageLength, maxMessageLength, maxTweakLength)
+	if err != nil {
+		return SmallDomain{}, "", err
+	}
+
+	smallDomain.alphabet = &alphabet
+	return smallDomain, warning, nil
+}
+
+// MinimumDomainSize returns the number of messages of the minimum length,
+// which is radix^minlen.
+func (smallDomain *SmallDomain) MinimumDomainSize() *big.Int {
+	return powInt(smallDomain.radix, smallDomain.minMessageLength)
+}
+
+// Destroy zeroes the copy of the key held by smallDomain and the shuffles it
+// has cached, and drops its block cipher, after which encryption and
+// decryption with smallDomain or any copy of it return ErrDestroyed. It waits
+// for calls already under way on other goroutines to finish first. As with
+// (ff1 *FF1) Destroy, the AES key schedule cannot be zeroed and is only
+// dropped.
+func (smallDomain *SmallDomain) Destroy() {
+	smallDomain.cipher.destroy(func() {
+		smallDomain.cache.mutex.Lock()
+		for _, table := range smallDomain.cache.tables {
+			zeroUint32s(table.forward)
+			zeroUint32s(table.inverse)
+		}
+		smallDomain.cache.tables = make(map[string]*smallDomainTable)
+		smallDomain.cache.mutex.Unlock()
+	})
+}
+
+// Encrypt encrypts a message using the shuffle for its length and tweak. It
+// returns the encrypted message, along with any error encountered during
+// encryption.
+// The plaintext argument should be the message to encrypt.
+// The tweak argument should be the tweak to use in the encryption process.
+func (smallDomain *SmallDomain) Encrypt(plaintext string, tweak []byte) (message string, err error) {
+	if smallDomain.alphabet == nil {
+		return message, errors.New("radix has no default alphabet: use EncryptNumerals or NewSmallDomainWithAlphabet")
+	}
+	numerals, err := smallDomain.alphabet.toNumerals(plaintext)
+	if err != nil {
+		return message, err
+	}
+	numerals, err = smallDomain.EncryptNumerals(numerals, tweak)
+	if err != nil {
+		return message, err
+	}
+	return smallDomain.alphabet.fromNumerals(numerals), nil
+}
+
+// Decrypt decrypts a message using the shuffle for its length and tweak. It
+// returns the decrypted message, along with any error encountered during
+// decryption.
+// The message argument should be the message to decrypt.
+// The tweak argument should be the tweak to use in the decryption process.
+func (smallDomain *SmallDomain) Decrypt(message string, tweak []byte) (plaintext string, err error) {
+	if smallDomain.alphabet == nil {
+		return plaintext, errors.New("radix has no default alphabet: use DecryptNumerals or NewSmallDomainWithAlphabet")
+	}
+	numerals, err := smallDomain.alphabet.toNumerals(message)
+	if err != nil {
+		return plaintext, err
+	}
+	numerals, err = smallDomain.DecryptNumerals(numerals, tweak)
+	if err != nil {
+		return plaintext, err
+	}
+	return smallDomain.alphabet.fromNumerals(numerals), nil
+}
+
+// EncryptNumerals encrypts a message given as a slice of numerals, each less
+// than the radix, and returns the encrypted numerals along with any error
+// encountered during encryption.
+// The plaintext argument should be the numerals to encrypt.
+// The tweak argument should be the tweak to use in the encryption process.
+func (smallDomain *SmallDomain) EncryptNumerals(plaintext []uint16, tweak []byte) (message []uint16, err error) {
+	cph, err := smallDomain.cipher.acquire()
+	if err != nil {
+		return message, err
+	}
+	defer smallDomain.cipher.release()
+	table, err := smallDomain.table(cph, plaintext, tweak)
+	if err != nil {
+		return message, err
+	}
+	return smallDomain.toNumerals(table.forward[smallDomain.fromNumerals(plaintext)], len(plaintext)), nil
+}
+
+// DecryptNumerals decrypts a message given as a slice of numerals, each less
+// than the radix, and returns the decrypted numerals along with any error
+// encountered during decryption.
+// The message argument should be the numerals to decrypt.
+// The tweak argument should be the tweak to use in the decryption process.
+func (smallDomain *SmallDomain) DecryptNumerals(message []uint16, tweak []byte) (plaintext []uint16, err error) {
+	cph, err := smallDomain.cipher.acquire()
+	if err != nil {
+		return plaintext, err
+	}
+	defer smallDomain.cipher.release()
+	table, err := smallDomain.table(cph, message, tweak)
+	if err != nil {
+		return plaintext, err
+	}
+	return smallDomain.toNumerals(table.inverse[smallDomain.fromNumerals(message)], len(message)), nil
+}
+
+// Utility Functions for SmallDomain
+
+// table checks a message and tweak and returns the shuffle for them, building
+// it with the block cipher cph and caching it if needed.
+func (smallDomain *SmallDomain) table(cph cipher.Block, message []uint16, tweak []byte) (*smallDomainTable, error) {
+	if len(message) < smallDomain.minMessageLength || len(message) > smallDomain.maxMessageLength {
+		return nil, errors.New("message length is not within min and max bounds")
+	}
+	if len(tweak) > smallDomain.maxTweakLength {
+		return nil, errors.New("tweak length is greater than the max tweak length")
+	}
+	if err := checkNumerals(message, smallDomain.radix); err != nil {
+		return nil, err
+	}
+
+	key := string(append([]byte{byte(len(message))}, tweak...))
+	cache := smallDomain.cache
+	cache.mutex.RLock()
+	table, found := cache.tables[key]
+	cache.mutex.RUnlock()
+	if found {
+		return table, nil
+	}
+
+	table = smallDomain.shuffle(cph, len(message), tweak)
+	cache.mutex.Lock()
+	if len(cache.tables) >= maxSmallDomainTables {
+		cache.tables = make(map[string]*smallDomainTable)
+	}
+	cache.tables[key] = table
+	cache.mutex.Unlock()
+	return table, nil
+}
+
+// shuffle builds the keyed shuffle of the messages of a length for a tweak.
+// A seed is computed as the CBC-MAC of a block encoding the radix, length and
+// tweak length followed by the zero padded tweak, which is prefix free, and
+// the Knuth shuffle draws its random numbers from the AES encryptions of the
+// seed XOR a counter, all with the block cipher cph.
+func (smallDomain *SmallDomain) shuffle(cph cipher.Block, length int, tweak []byte) *smallDomainTable {
+	blocks := make([]byte, 16+16*ceilRsh(len(tweak), 4))
+	copy(blocks, []byte{'S', 'D', 1})
+	blocks[3] = byte(smallDomain.radix >> 16)
+	blocks[4] = byte(smallDomain.radix >> 8)
+	blocks[5] = byte(smallDomain.radix)
+	binary.BigEndian.PutUint32(blocks[6:10], uint32(length))
+	binary.BigEndian.PutUint32(blocks[10:14], uint32(len(tweak)))
+	copy(blocks[16:], tweak)
+
+	var seed [16]byte
+	for i := 0; i < len(blocks); i += 16 {
+		for j := 0; j < 16; j++ {
+			seed[j] ^= blocks[i+j]
+		}
+		cph.Encrypt(seed[:], seed[:])
+	}
+
+	size := 1
+	for i := 0; i < length; i++ {
+		size *= smallDomain.radix
+	}
+	stream := smallDomainStream{cipher: cph, seed: seed}
+	forward := make([]uint32, size)
+	for i := range forward {
+		forward[i] = uint32(i)
+	}
+	for i := size - 1; i > 0; i-- {
+		j := stream.uniform(uint32(i + 1))
+		forward[i], forward[j] = forward[j], forward[i]
+	}
+
+	inverse := make([]uint32, size)
+	for i, v := range forward {
+		inverse[v] = uint32(i)
+	}
+	return &smallDomainTable{forward: forward, inverse: inverse}
+}
+
+// fromNumerals returns the number the numerals write in the radix.
+func (smallDomain *SmallDomain) fromNumerals(numerals []uint16) uint32 {
+	x := uint32(0)
+	for _, n := range numerals {
+		x = x*uint32(smallDomain.radix) + uint32(n)
+	}
+	return x
+}
+
+// toNumerals returns the length numerals that write x in the radix.
+func (smallDomain *SmallDomain) toNumerals(x uint32, length int) []uint16 {
+	numerals := make([]uint16, length)
+	for i := length - 1; i >= 0; i-- {
+		numerals[i] = uint16(x % uint32(smallDomain.radix))
+		x /= uint32(smallDomain.radix)
+	}
+	return numerals
+}
+
+// The smallDomainStream type produces the random numbers of a shuffle as the
+// AES encryptions of its seed XOR a counter.
+type smallDomainStream struct {
+	cipher  cipher.Block
+	seed    [16]byte
+	counter uint64
+	block   [16]byte
+	used    int
+}
+
+// next returns the next 32 bits of the stream.
+func (stream *smallDomainStream) next() uint32 {
+	if stream.used == 0 || stream.used == 16 {
+		stream.counter++
+		stream.block = stream.seed
+		var counter [8]byte
+		binary.BigEndian.PutUint64(counter[:], stream.counter)
+		for i := 0; i < 8; i++ {
+			stream.block[8+i] ^= counter[i]
+		}
+		stream.cipher.Encrypt(stream.block[:], stream.block[:])
+		stream.used = 0
+	}
+	x := binary.BigEndian.Uint32(stream.block[stream.used : stream.used+4])
+	stream.used += 4
+	return x
+}
+
+// uniform returns a number below n with every number equally likely, by
+// rejecting the values from the stream that would bias the remainder.
+func (stream *smallDomainStream) uniform(n uint32) uint32 {
+	limit := (1 << 32) / uint64(n) * uint64(n)
+	for {
+		x := stream.next()
+		if uint64(x) < limit {
+			return x % n
+		}
+	}
+}
+
+// zeroUint32s overwrites a shuffle table with zeros.
+func zeroUint32s(values []uint32) {
+	for i := range values {
+		values[i] = 0
+	}
+}
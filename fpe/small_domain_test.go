@@ -0,0 +1,152 @@
+package fpe
+
+import (
+	"testing"
+)
+
+// assertSmallDomainPermutation encrypts every message of one length and
+// checks that the results are distinct messages of that length that decrypt
+// back.
+func assertSmallDomainPermutation(t *testing.T, smallDomain SmallDomain, length int, tweak []byte) {
+	size := 1
+	for i := 0; i < length; i++ {
+		size *= smallDomain.radix
+	}
+	seen := make(map[uint32]bool, size)
+	for i := 0; i < size; i++ {
+		plaintext := smallDomain.toNumerals(uint32(i), length)
+		msg, err := smallDomain.EncryptNumerals(plaintext, tweak)
+		assertNoError(t, err)
+		x := smallDomain.fromNumerals(msg)
+		if len(msg) != length || seen[x] {
+			t.Fatalf("Expected a new message of length %d, got %v", length, msg)
+		}
+		seen[x] = true
+		decrypted, err := smallDomain.DecryptNumerals(msg, tweak)
+		assertNoError(t, err)
+		assertExpectedNumerals(t, plaintext, decrypted)
+	}
+}
+
+func TestSmallDomainEncrypt(t *testing.T) {
+	t.Log("Testing SmallDomain encryption of a two digit message... ")
+	smallDomain, warning, err := NewSmallDomain("2B7E151628AED2A6ABF7158809CF4F3C", 10, 1, 2, 16)
+	assertNoError(t, err)
+	assertExpectedResult(t, string(SmallDomainWarning), string(warning))
+	msg, err := smallDomain.Encrypt("42", []byte{})
+	assertNoError(t, err)
+	assertExpectedResult(t, "67", msg)
+}
+
+func TestSmallDomainDecrypt(t *testing.T) {
+	t.Log("Testing SmallDomain decryption of a two digit message... ")
+	smallDomain, _, err := NewSmallDomain("2B7E151628AED2A6ABF7158809CF4F3C", 10, 1, 2, 16)
+	assertNoError(t, err)
+	plaintext, err := smallDomain.Decrypt("67", []byte{})
+	assertNoError(t, err)
+	assertExpectedResult(t, "42", plaintext)
+}
+
+func TestSmallDomainWithTweak(t *testing.T) {
+	t.Log("Testing SmallDomain encryption of a two digit message with a tweak... ")
+	smallDomain, _, err := NewSmallDomain("2B7E151628AED2A6ABF7158809CF4F3C", 10, 1, 2, 16)
+	assertNoError(t, err)
+	tweak := []byte{0x39, 0x38, 0x37, 0x36, 0x35, 0x34, 0x33, 0x32, 0x31, 0x30}
+	msg, err := smallDomain.Encrypt("42", tweak)
+	assertNoError(t, err)
+	assertExpectedResult(t, "82", msg)
+}
+
+func TestSmallDomainSingleDigit(t *testing.T) {
+	t.Log("Testing SmallDomain encryption of every single digit message... ")
+	smallDomain, _, err := NewSmallDomain("2B7E151628AED2A6ABF7158809CF4F3C", 10, 1, 2, 16)
+	assertNoError(t, err)
+	expected := "6903742158"
+	for i, r := range "0123456789" {
+		msg, err := smallDomain.Encrypt(string(r), []byte{})
+		assertNoError(t, err)
+		assertExpectedResult(t, expected[i:i+1], msg)
+	}
+}
+
+func TestSmallDomainWithAlphabet(t *testing.T) {
+	t.Log("Testing SmallDomain encryption with an alphabet... ")
+	alphabet, err := NewAlphabet("NY")
+	assertNoError(t, err)
+	smallDomain, _, err := NewSmallDomainWithAlphabet("2B7E151628AED2A6ABF7158809CF4F3C", alphabet, 3, 3, 16)
+	assertNoError(t, err)
+	msg, err := smallDomain.Encrypt("YNN", []byte{})
+	assertNoError(t, err)
+	assertExpectedResult(t, "NYY", msg)
+	plaintext, err := smallDomain.Decrypt(msg, []byte{})
+	assertNoError(t, err)
+	assertExpectedResult(t, "YNN", plaintext)
+}
+
+func TestSmallDomainPermutation(t *testing.T) {
+	t.Log("Testing SmallDomain round trips every message of each length... ")
+	smallDomain, _, err := NewSmallDomain("2B7E151628AED2A6ABF7158809CF4F3C", 7, 1, 3, 16)
+	assertNoError(t, err)
+	for length := 1; length <= 3; length++ {
+		assertSmallDomainPermutation(t, smallDomain, length, []byte{})
+		assertSmallDomainPermutation(t, smallDomain, length, []byte{0x01, 0x02, 0x03})
+	}
+}
+
+func TestSmallDomainCacheLimit(t *testing.T) {
+	t.Log("Testing SmallDomain keeps a bounded number of cached tables... ")
+	smallDomain, _, err := NewSmallDomain("2B7E151628AED2A6ABF7158809CF4F3C", 10, 1, 1, 2)
+	assertNoError(t, err)
+	for i := 0; i < 2*maxSmallDomainTables; i++ {
+		_, err := smallDomain.Encrypt("5", []byte{byte(i >> 8), byte(i)})
+		assertNoError(t, err)
+	}
+	if len(smallDomain.cache.tables) > maxSmallDomainTables {
+		t.Fatalf("Expected at most %d tables, got %d", maxSmallDomainTables, len(smallDomain.cache.tables))
+	}
+}
+
+func TestSmallDomainDomainTooLarge(t *testing.T) {
+	t.Log("Testing SmallDomain refuses domains above 2^16 messages... ")
+	_, _, err := NewSmallDomain("2B7E151628AED2A6ABF7158809CF4F3C", 10, 1, 5, 16)
+	assertError(t, err)
+}
+
+func TestSmallDomainMessageTooLong(t *testing.T) {
+	t.Log("Testing SmallDomain refuses messages longer than the maximum length... ")
+	smallDomain, _, err := NewSmallDomain("2B7E151628AED2A6ABF7158809CF4F3C", 10, 1, 2, 16)
+	assertNoError(t, err)
+	_, err = smallDomain.Encrypt("123", []byte{})
+	assertError(t, err)
+}
+
+func TestSmallDomainDestroy(t *testing.T) {
+	t.Log("Testing SmallDomain refuses to encrypt and zeroes its tables once destroyed... ")
+	smallDomain, _, err := NewSmallDomain("2B7E151628AED2A6ABF7158809CF4F3C", 10, 1, 2, 16)
+	assertNoError(t, err)
+	_, err = smallDomain.Encrypt("42", []byte{})
+	assertNoError(t, err)
+	table := smallDomain.cache.tables[string([]byte{2})]
+	if table == nil {
+		t.Fatalf("Expected a cached table for 2 digits")
+	}
+	smallDomain.Destroy()
+	for i := range table.forward {
+		if table.forward[i] != 0 || table.inverse[i] != 0 {
+			t.Fatalf("Expected the cached table to be zeroed")
+		}
+	}
+	if len(smallDomain.cache.tables) != 0 {
+		t.Errorf("Expected no cached tables, got %d", len(smallDomain.cache.tables))
+	}
+	_, err = smallDomain.Encrypt("42", []byte{})
+	if err != ErrDestroyed {
+		t.Errorf("Expected ErrDestroyed, got %v", err)
+	}
+}
+
+func TestFF1SmallDomainError(t *testing.T) {
+	t.Log("Testing NewFF1 refuses a domain below the NIST minimum... ")
+	_, err := NewFF1("2B7E151628AED2A6ABF7158809CF4F3C", 5, 2, 4, 16)
+	assertError(t, err)
+}
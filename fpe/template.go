@@ -0,0 +1,163 @@
+package fpe
+
+import (
+	"errors"
+	"math/big"
+)
+
+// templatePlaceholder marks a position of a template that is encrypted.
+const templatePlaceholder = 'D'
+
+// templateEscape makes the character after it a literal, so that a template
+// can contain a literal 'D' or '\'.
+const templateEscape = '\\'
+
+// The Template type allows for encryption and decryption of formatted messages
+// such as "123-45-6789", where only some positions are encrypted and the
+// separators and other fixed characters are kept in place. See the
+// NewTemplate, (template *Template) Encrypt, and (template *Template) Decrypt
+// functions for more detail.
+type Template struct {
+	algorithm Algorithm
+	pattern   string
+	// literals holds the fixed character for each position of the template,
+	// or 0 for the positions that are encrypted.
+	literals []rune
+}
+
+// NewTemplate returns a new Template struct for encrypting and decrypting
+// messages that follow a format template. It will also return any errors
+// encountered in parsing the template.
+// The algorithm argument should be the algorithm used to encrypt the variable
+// positions of the template. They are encrypted together as one message, so
+// the number of variable positions must be allowed by the algorithm.
+// The pattern argument should be the template. Every 'D' is a position that
+// is encrypted, and every other character must appear unchanged in messages.
+// A '\' makes the character after it a literal, so "\D" is a literal 'D'. For
+// example, "DDD-DD-DDDD" for social security numbers, "(DDD) DDD-DDDD" for
+// phone numbers or "UH-DDDDD-A" for member IDs.
+func NewTemplate(algorithm Algorithm, pattern string) (template Template, err error) {
+	var literals []rune
+	variablePositions := 0
+	escaped := false
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			literals = append(literals, r)
+			escaped = false
+		case r == templateEscape:
+			escaped = true
+		case r == templatePlaceholder:
+			literals = append(literals, 0)
+			variablePositions++
+		default:
+			literals = append(literals, r)
+		}
+	}
+
+	if escaped {
+		return Template{}, errors.New("template ended with an escape character")
+	}
+	if variablePositions == 0 {
+		return Template{}, errors.New("template had no positions to encrypt")
+	}
+
+	return Template{
+		algorithm: algorithm,
+		pattern:   pattern,
+		literals:  literals}, nil
+}
+
+// String returns the template the Template was made with.
+func (template *Template) String() string {
+	return template.pattern
+}
+
+// MinimumDomainSize returns the minimum domain size of the algorithm the
+// variable positions are encrypted with, or nil if it cannot be told.
+func (template *Template) MinimumDomainSize() *big.Int {
+	if sizer, ok := template.algorithm.(DomainSizer); ok {
+		return sizer.MinimumDomainSize()
+	}
+	return nil
+}
+
+// Encrypt encrypts the variable positions of a message that follows the
+// template. It returns the encrypted message, with the fixed characters of
+// the template in place, along with any error encountered during encryption.
+// The plaintext argument should be the message to encrypt.
+// The tweak argument should be the tweak to use in the encryption process.
+func (template *Template) Encrypt(plaintext string, tweak []byte) (message string, err error) {
+	variable, err := template.extract(plaintext)
+	if err != nil {
+		return message, err
+	}
+	variable, err = template.algorithm.Encrypt(variable, tweak)
+	if err != nil {
+		return message, err
+	}
+	return template.fill(variable)
+}
+
+// Decrypt decrypts the variable positions of a message that follows the
+// template. It returns the decrypted message, with the fixed characters of
+// the template in place, along with any error encountered during decryption.
+// The message argument should be the message to decrypt.
+// The tweak argument should be the tweak to use in the decryption process.
+func (template *Template) Decrypt(message string, tweak []byte) (plaintext string, err error) {
+	variable, err := template.extract(message)
+	if err != nil {
+		return plaintext, err
+	}
+	variable, err = template.algorithm.Decrypt(variable, tweak)
+	if err != nil {
+		return plaintext, err
+	}
+	return template.fill(variable)
+}
+
+// Utility Functions for Template
+
+// extract checks that a message follows the template and returns the
+// characters in its variable positions.
+func (template *Template) extract(message string) (string, error) {
+	runes := []rune(message)
+	if len(runes) != len(template.literals) {
+		return "", errors.New("message length did not match the template")
+	}
+
+	variable := make([]rune, 0, len(runes))
+	for i, r := range runes {
+		switch template.literals[i] {
+		case 0:
+			variable = append(variable, r)
+		case r:
+		default:
+			return "", errors.New("message did not match the fixed characters of the template")
+		}
+	}
+	return string(variable), nil
+}
+
+// fill returns the template with its variable positions replaced by the
+// characters of variable, in order.
+func (template *Template) fill(variable string) (string, error) {
+	runes := []rune(variable)
+	message := make([]rune, len(template.literals))
+	next := 0
+	for i, literal := range template.literals {
+		if literal != 0 {
+			message[i] = literal
+			continue
+		}
+		if next >= len(runes) {
+			return "", errors.New("algorithm changed the length of the message")
+		}
+		message[i] = runes[next]
+		next++
+	}
+	if next != len(runes) {
+		return "", errors.New("algorithm changed the length of the message")
+	}
+	return string(message), nil
+}
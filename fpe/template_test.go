@@ -0,0 +1,81 @@
+package fpe
+
+import (
+	"testing"
+)
+
+func TestTemplateEncrypt(t *testing.T) {
+	t.Log("Testing Template encryption with separators... ")
+	ff1, err := NewFF1("2B7E151628AED2A6ABF7158809CF4F3C", 10, 2, 20, 16)
+	assertNoError(t, err)
+	template, err := NewTemplate(&ff1, "DDD-DDD-DDDD")
+	assertNoError(t, err)
+	msg, err := template.Encrypt("012-345-6789", []byte{})
+	assertNoError(t, err)
+	assertExpectedResult(t, "243-347-7484", msg)
+}
+
+func TestTemplateDecrypt(t *testing.T) {
+	t.Log("Testing Template decryption with separators... ")
+	ff1, err := NewFF1("2B7E151628AED2A6ABF7158809CF4F3C", 10, 2, 20, 16)
+	assertNoError(t, err)
+	template, err := NewTemplate(&ff1, "DDD-DDD-DDDD")
+	assertNoError(t, err)
+	plaintext, err := template.Decrypt("243-347-7484", []byte{})
+	assertNoError(t, err)
+	assertExpectedResult(t, "012-345-6789", plaintext)
+}
+
+func TestTemplateWithLiteralsAndEscapes(t *testing.T) {
+	t.Log("Testing Template round trip with escaped literals... ")
+	ff31, err := NewFF31("EF4359D8D580AA4F7F036D6F04FC6A94", 10, 2, 20)
+	assertNoError(t, err)
+	template, err := NewTemplate(&ff31, `UH\D-DDDDD-A\\`)
+	assertNoError(t, err)
+	assertExpectedResult(t, `UH\D-DDDDD-A\\`, template.String())
+	tweak := []byte{0xD8, 0xE7, 0x92, 0x0A, 0xFA, 0x33, 0x0A}
+	msg, err := template.Encrypt(`UHD-00123-A\`, tweak)
+	assertNoError(t, err)
+	if msg[:4] != "UHD-" || msg[9:] != `-A\` {
+		t.Fatalf("Expected the literals to be kept, got %s", msg)
+	}
+	plaintext, err := template.Decrypt(msg, tweak)
+	assertNoError(t, err)
+	assertExpectedResult(t, `UHD-00123-A\`, plaintext)
+}
+
+func TestTemplateWithMismatchedLiteral(t *testing.T) {
+	t.Log("Testing Template encryption with a wrong separator... ")
+	ff1, err := NewFF1("2B7E151628AED2A6ABF7158809CF4F3C", 10, 2, 20, 16)
+	assertNoError(t, err)
+	template, err := NewTemplate(&ff1, "(DDD) DDD-DDDD")
+	assertNoError(t, err)
+	_, err = template.Encrypt("(702)-555-0100", []byte{})
+	assertError(t, err)
+}
+
+func TestTemplateWithWrongLength(t *testing.T) {
+	t.Log("Testing Template encryption with a message of the wrong length... ")
+	ff1, err := NewFF1("2B7E151628AED2A6ABF7158809CF4F3C", 10, 2, 20, 16)
+	assertNoError(t, err)
+	template, err := NewTemplate(&ff1, "DDD-DD-DDDD")
+	assertNoError(t, err)
+	_, err = template.Encrypt("123-45-678", []byte{})
+	assertError(t, err)
+}
+
+func TestNewTemplateWithTrailingEscape(t *testing.T) {
+	t.Log("Testing NewTemplate with a trailing escape character... ")
+	ff1, err := NewFF1("2B7E151628AED2A6ABF7158809CF4F3C", 10, 2, 20, 16)
+	assertNoError(t, err)
+	_, err = NewTemplate(&ff1, `DDD-\`)
+	assertError(t, err)
+}
+
+func TestNewTemplateWithoutPlaceholders(t *testing.T) {
+	t.Log("Testing NewTemplate with no positions to encrypt... ")
+	ff1, err := NewFF1("2B7E151628AED2A6ABF7158809CF4F3C", 10, 2, 20, 16)
+	assertNoError(t, err)
+	_, err = NewTemplate(&ff1, `\D\D-00`)
+	assertError(t, err)
+}
@@ -0,0 +1,59 @@
+package fpe
+
+import (
+	"math/bits"
+)
+
+// These functions do the arithmetic of int_math.go in fixed width integers,
+// for the fast paths of FF1 and FF3 used when the halves of a message are
+// below 2^64. They do not allocate.
+
+// numeralsToUint64 returns the number represented by a slice of numerals in
+// the given radix, most significant numeral first, which must be below 2^64.
+func numeralsToUint64(numerals []uint16, radix uint64) uint64 {
+	x := uint64(0)
+	for _, n := range numerals {
+		x = x*radix + uint64(n)
+	}
+	return x
+}
+
+// uint64ToNumerals writes the numerals that represent x in the given radix
+// into numerals, most significant numeral first. x must be less than
+// radix^len(numerals).
+func uint64ToNumerals(numerals []uint16, x, radix uint64) {
+	for i := len(numerals) - 1; i >= 0; i-- {
+		numerals[i] = uint16(x % radix)
+		x /= radix
+	}
+}
+
+// powUint64 returns radix^exponent, and whether it is below 2^64.
+func powUint64(radix uint64, exponent int) (uint64, bool) {
+	x := uint64(1)
+	for i := 0; i < exponent; i++ {
+		hi, lo := bits.Mul64(x, radix)
+		if hi != 0 {
+			return 0, false
+		}
+		x = lo
+	}
+	return x, true
+}
+
+// addMod returns (a + b) mod m for a and b less than m.
+func addMod(a, b, m uint64) uint64 {
+	sum, carry := bits.Add64(a, b, 0)
+	if carry != 0 || sum >= m {
+		sum -= m
+	}
+	return sum
+}
+
+// subMod returns (a - b) mod m for a and b less than m.
+func subMod(a, b, m uint64) uint64 {
+	if a >= b {
+		return a - b
+	}
+	return m - (b - a)
+}
@@ -0,0 +1,159 @@
+package fpe
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"unicode/utf8"
+)
+
+// The VersionIndicator type writes the key version a message was encrypted
+// with into the leading characters of the message, so that Decrypt can pick
+// the algorithm of that version without being told. The indicator is the key
+// version modulo radix^width of its alphabet, so it tells apart any
+// radix^width consecutive versions. See NewVersionIndicator for more detail.
+type VersionIndicator struct {
+	alphabet         Alphabet
+	width            int
+	modulus          int
+	keyVersion       int
+	latestKeyVersion int
+	lookup           func(keyVersion int) (Algorithm, error)
+}
+
+// NewVersionIndicator returns a new VersionIndicator, along with any error
+// encountered in checking its settings. The messages it returns are width
+// indicator characters followed by a message of the algorithm of the key
+// version, so that algorithm should be made for messages width characters
+// shorter than the format allows.
+// The alphabet argument should be the characters the indicator is written in.
+// The width argument should be the number of characters of the indicator.
+// The keyVersion argument should be the key version Encrypt uses.
+// The latestKeyVersion argument should be the newest key version. Decrypt
+// reads an indicator as the newest version up to it that the indicator fits.
+// The lookup argument should return the algorithm of a key version, or an
+// error if it is not available.
+func NewVersionIndicator(alphabet Alphabet, width, keyVersion, latestKeyVersion int, lookup func(keyVersion int) (Algorithm, error)) (VersionIndicator, error) {
+	if alphabet.Radix() < 2 {
+		return VersionIndicator{}, errors.New("version indicator needs an alphabet of at least 2 characters")
+	}
+	if width < 1 {
+		return VersionIndicator{}, errors.New("version indicator must be at least 1 character wide")
+	}
+	modulus := 1
+	for i := 0; i < width; i++ {
+		if modulus > math.MaxInt32/alphabet.Radix() {
+			return VersionIndicator{}, errors.New("version indicator is too wide")
+		}
+		modulus *= alphabet.Radix()
+	}
+	if keyVersion < 0 || keyVersion > latestKeyVersion {
+		return VersionIndicator{}, errors.New("key version must be between 0 and the latest key version")
+	}
+	if lookup == nil {
+		return VersionIndicator{}, errors.New("version indicator needs a lookup for the algorithm of each key version")
+	}
+
+	return VersionIndicator{
+		alphabet:         alphabet,
+		width:            width,
+		modulus:          modulus,
+		keyVersion:       keyVersion,
+		latestKeyVersion: latestKeyVersion,
+		lookup:           lookup}, nil
+}
+
+// Width returns the number of characters of the indicator.
+func (indicator *VersionIndicator) Width() int {
+	return indicator.width
+}
+
+// MinimumDomainSize returns the minimum domain size of the algorithm of the
+// key version Encrypt uses, or nil if it cannot be told. The indicator adds
+// nothing to the domain, since it is the same for every message of a version.
+func (indicator *VersionIndicator) MinimumDomainSize() *big.Int {
+	algorithm, err := indicator.lookup(indicator.keyVersion)
+	if err != nil {
+		return nil
+	}
+	if sizer, ok := algorithm.(DomainSizer); ok {
+		return sizer.MinimumDomainSize()
+	}
+	return nil
+}
+
+// Encrypt encrypts a plaintext with the algorithm of the key version of the
+// VersionIndicator. It returns the indicator of the key version followed by
+// the encrypted message, along with any error encountered during encryption.
+// The plaintext argument should be the message to encrypt.
+// The tweak argument should be the tweak to use in the encryption process.
+func (indicator *VersionIndicator) Encrypt(plaintext string, tweak []byte) (message string, err error) {
+	algorithm, err := indicator.lookup(indicator.keyVersion)
+	if err != nil {
+		return message, err
+	}
+	message, err = algorithm.Encrypt(plaintext, tweak)
+	if err != nil {
+		return "", err
+	}
+	return indicator.format(indicator.keyVersion) + message, nil
+}
+
+// Decrypt decrypts a message with the algorithm of the key version in its
+// indicator. It returns the decrypted message, without the indicator, along
+// with any error encountered during decryption.
+// The message argument should be the message to decrypt.
+// The tweak argument should be the tweak to use in the decryption process.
+func (indicator *VersionIndicator) Decrypt(message string, tweak []byte) (plaintext string, err error) {
+	keyVersion, rest, err := indicator.parse(message)
+	if err != nil {
+		return plaintext, err
+	}
+	algorithm, err := indicator.lookup(keyVersion)
+	if err != nil {
+		return plaintext, err
+	}
+	return algorithm.Decrypt(rest, tweak)
+}
+
+// Utility Functions for VersionIndicator
+
+// format returns the indicator of a key version, written most significant
+// character first.
+func (indicator *VersionIndicator) format(keyVersion int) string {
+	value := keyVersion % indicator.modulus
+	numerals := make([]uint16, indicator.width)
+	for i := indicator.width - 1; i >= 0; i-- {
+		numerals[i] = uint16(value % indicator.alphabet.Radix())
+		value /= indicator.alphabet.Radix()
+	}
+	return indicator.alphabet.fromNumerals(numerals)
+}
+
+// parse splits a message into the key version of its indicator and the rest
+// of the message. The key version is the newest one, up to the latest key
+// version, whose indicator matches.
+func (indicator *VersionIndicator) parse(message string) (keyVersion int, rest string, err error) {
+	end := 0
+	for i := 0; i < indicator.width; i++ {
+		if end >= len(message) {
+			return 0, "", errors.New("message is shorter than its version indicator")
+		}
+		_, size := utf8.DecodeRuneInString(message[end:])
+		end += size
+	}
+	numerals, err := indicator.alphabet.toNumerals(message[:end])
+	if err != nil {
+		return 0, "", errors.New("version indicator contained a character that is not in its alphabet")
+	}
+
+	value := 0
+	for _, numeral := range numerals {
+		value = value*indicator.alphabet.Radix() + int(numeral)
+	}
+	behind := (indicator.latestKeyVersion - value) % indicator.modulus
+	if behind < 0 {
+		behind += indicator.modulus
+	}
+	return indicator.latestKeyVersion - behind, message[end:], nil
+}
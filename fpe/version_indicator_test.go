@@ -0,0 +1,98 @@
+package fpe
+
+import (
+	"errors"
+	"testing"
+)
+
+// versionedFF1 returns a lookup of an FF1 algorithm with a different key for
+// each of the key versions 0 to 9.
+func versionedFF1(t *testing.T) func(keyVersion int) (Algorithm, error) {
+	keys := []string{
+		"2B7E151628AED2A6ABF7158809CF4F3C", "2B7E151628AED2A6ABF7158809CF4F3D",
+		"2B7E151628AED2A6ABF7158809CF4F3E", "2B7E151628AED2A6ABF7158809CF4F3F",
+		"2B7E151628AED2A6ABF7158809CF4F40", "2B7E151628AED2A6ABF7158809CF4F41",
+		"2B7E151628AED2A6ABF7158809CF4F42", "2B7E151628AED2A6ABF7158809CF4F43",
+		"2B7E151628AED2A6ABF7158809CF4F44", "2B7E151628AED2A6ABF7158809CF4F45",
+	}
+	algorithms := make([]Algorithm, len(keys))
+	for i, key := range keys {
+		ff1, err := NewFF1(key, 10, 6, 8, 16)
+		assertNoError(t, err)
+		algorithms[i] = &ff1
+	}
+	return func(keyVersion int) (Algorithm, error) {
+		if keyVersion < 0 || keyVersion >= len(algorithms) {
+			return nil, errors.New("key version is not available")
+		}
+		return algorithms[keyVersion], nil
+	}
+}
+
+func TestVersionIndicator(t *testing.T) {
+	t.Log("Testing VersionIndicator writes and reads the key version... ")
+	lookup := versionedFF1(t)
+	alphabet, err := NewAlphabet("0123456789")
+	assertNoError(t, err)
+	latest, err := NewVersionIndicator(alphabet, 1, 3, 3, lookup)
+	assertNoError(t, err)
+
+	for keyVersion := 1; keyVersion <= 3; keyVersion++ {
+		indicator, err := NewVersionIndicator(alphabet, 1, keyVersion, 3, lookup)
+		assertNoError(t, err)
+		message, err := indicator.Encrypt("0123456", []byte{})
+		assertNoError(t, err)
+		if len(message) != 8 || message[0] != byte('0'+keyVersion) {
+			t.Fatalf("Expected an indicator of %d, got %s", keyVersion, message)
+		}
+		algorithm, _ := lookup(keyVersion)
+		expected, _ := algorithm.Encrypt("0123456", []byte{})
+		assertExpectedResult(t, expected, message[1:])
+
+		plaintext, err := latest.Decrypt(message, []byte{})
+		assertNoError(t, err)
+		assertExpectedResult(t, "0123456", plaintext)
+	}
+}
+
+func TestVersionIndicatorWrapsAround(t *testing.T) {
+	t.Log("Testing VersionIndicator reads the newest key version an indicator fits... ")
+	lookup := versionedFF1(t)
+	alphabet, err := NewAlphabet("ab")
+	assertNoError(t, err)
+	previous, err := NewVersionIndicator(alphabet, 2, 6, 9, lookup)
+	assertNoError(t, err)
+	message, err := previous.Encrypt("0123456", []byte{})
+	assertNoError(t, err)
+	if message[:2] != "ba" {
+		t.Fatalf("Expected an indicator of ba, got %s", message)
+	}
+
+	latest, err := NewVersionIndicator(alphabet, 2, 9, 9, lookup)
+	assertNoError(t, err)
+	plaintext, err := latest.Decrypt(message, []byte{})
+	assertNoError(t, err)
+	assertExpectedResult(t, "0123456", plaintext)
+
+	_, err = latest.Decrypt("Z"+message[1:], []byte{})
+	assertError(t, err)
+	_, err = latest.Decrypt("b", []byte{})
+	assertError(t, err)
+}
+
+func TestVersionIndicatorSettings(t *testing.T) {
+	t.Log("Testing NewVersionIndicator refuses bad settings... ")
+	lookup := versionedFF1(t)
+	alphabet, err := NewAlphabet("0123456789")
+	assertNoError(t, err)
+	_, err = NewVersionIndicator(alphabet, 0, 1, 1, lookup)
+	assertError(t, err)
+	_, err = NewVersionIndicator(alphabet, 10, 1, 1, lookup)
+	assertError(t, err)
+	_, err = NewVersionIndicator(alphabet, 1, 2, 1, lookup)
+	assertError(t, err)
+	_, err = NewVersionIndicator(alphabet, 1, 1, 1, nil)
+	assertError(t, err)
+	_, err = NewVersionIndicator(Alphabet{}, 1, 1, 1, lookup)
+	assertError(t, err)
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/unitehere/format-preserving-encryption/fpe"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	// dataKeySize is the size in bytes of the data keys generated for arks,
+	// which are AES-256 keys, and of the master key of a local key provider.
+	dataKeySize = 32
+	// localKeySaltSize is the size in bytes of the salt of a passphrase
+	// protected local key file.
+	localKeySaltSize = 16
+	// localKeyIterations is the number of PBKDF2 iterations that turn the
+	// passphrase of a local key file into the key protecting it.
+	localKeyIterations = 600000
+)
+
+// The KeyProvider interface holds a master key and encrypts secrets under it,
+// so that the service key in the keyfile and the data keys of arks in the
+// arks table are only stored encrypted.
+type KeyProvider interface {
+	// Encrypt encrypts a secret under the master key, such as the service
+	// key of a new keyfile.
+	Encrypt(plaintext []byte) (ciphertext []byte, err error)
+	// Decrypt decrypts a secret encrypted by Encrypt.
+	Decrypt(ciphertext []byte) (plaintext []byte, err error)
+	// WrapKey encrypts a data key under the master key. The label, such as the
+	// name of the ark, is bound to the wrapped key, so that it cannot be
+	// unwrapped for anything else. A data key can be rotated or destroyed
+	// without touching the master key.
+	WrapKey(key []byte, label string) (wrapped []byte, err error)
+	// UnwrapKey decrypts a data key wrapped by WrapKey with the same label.
+	UnwrapKey(wrapped []byte, label string) (key []byte, err error)
+}
+
+// newDataKey returns a new random data key.
+func newDataKey() ([]byte, error) {
+	key := make([]byte, dataKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// The kmsKeyProvider type encrypts with a key held by AWS KMS, which never
+// leaves KMS. The label of a wrapped key is passed as the encryption context.
+type kmsKeyProvider struct {
+	client *kms.KMS
+	// keyID is the id or alias of the KMS key new secrets are encrypted with.
+	// Decrypting does not need it, since KMS records the key in the blob.
+	keyID string
+}
+
+func (provider *kmsKeyProvider) Encrypt(plaintext []byte) ([]byte, error) {
+	return provider.encrypt(plaintext, nil)
+}
+
+func (provider *kmsKeyProvider) Decrypt(ciphertext []byte) ([]byte, error) {
+	return provider.decrypt(ciphertext, nil)
+}
+
+func (provider *kmsKeyProvider) WrapKey(key []byte, label string) ([]byte, error) {
+	return provider.encrypt(key, kmsEncryptionContext(label))
+}
+
+func (provider *kmsKeyProvider) UnwrapKey(wrapped []byte, label string) ([]byte, error) {
+	key, err := provider.decrypt(wrapped, kmsEncryptionContext(label))
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != dataKeySize {
+		return nil, errors.New("unwrapped data key is not 32 bytes")
+	}
+	return key, nil
+}
+
+func (provider *kmsKeyProvider) encrypt(plaintext []byte, context map[string]*string) ([]byte, error) {
+	if provider.keyID == "" {
+		return nil, errors.New("KMS_KEY_ID must be set to encrypt with KMS")
+	}
+	output, err := provider.client.Encrypt(&kms.EncryptInput{
+		KeyId:             aws.String(provider.keyID),
+		Plaintext:         plaintext,
+		EncryptionContext: context,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return output.CiphertextBlob, nil
+}
+
+func (provider *kmsKeyProvider) decrypt(ciphertext []byte, context map[string]*string) ([]byte, error) {
+	output, err := provider.client.Decrypt(&kms.DecryptInput{
+		CiphertextBlob:    ciphertext,
+		EncryptionContext: context,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return output.Plaintext, nil
+}
+
+// kmsEncryptionContext returns the KMS encryption context that binds a wrapped
+// key to its label.
+func kmsEncryptionContext(label string) map[string]*string {
+	return map[string]*string{"label": aws.String(label)}
+}
+
+// The localKeyProvider type encrypts with AES-256-GCM under a master key read
+// from a local key file, so the server can run without AWS. Each ciphertext is
+// a random nonce followed by the sealed secret, with additional data that
+// keeps secrets and the wrapped keys of different labels apart.
+type localKeyProvider struct {
+	aead cipher.AEAD
+}
+
+// newLocalKeyProvider returns a localKeyProvider for the master key in a
+// local key file, as written by newLocalKeyFile. If passphrase is empty the
+// file holds the master key in hexadecimal and is protected only by its
+// permissions. Otherwise the file holds the master key encrypted under a key
+// derived from the passphrase.
+func newLocalKeyProvider(keyFile, passphrase string) (*localKeyProvider, error) {
+	contents, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	decoded, err := hex.DecodeString(strings.TrimSpace(string(contents)))
+	fpe.ZeroBytes(contents)
+	if err != nil {
+		return nil, err
+	}
+	defer fpe.ZeroBytes(decoded)
+
+	masterKey := decoded
+	if passphrase != "" {
+		if len(decoded) < localKeySaltSize {
+			return nil, errors.New("local key file is too short")
+		}
+		protection, err := newLocalProtection(passphrase, decoded[:localKeySaltSize])
+		if err != nil {
+			return nil, err
+		}
+		masterKey, err = protection.open(decoded[localKeySaltSize:], []byte("fpe local master key"))
+		if err != nil {
+			return nil, errors.New("local key file could not be decrypted: is the passphrase right?")
+		}
+		defer fpe.ZeroBytes(masterKey)
+	}
+	if len(masterKey) != dataKeySize {
+		return nil, errors.New("local master key must be 32 bytes")
+	}
+	block, err := aes.NewCipher(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &localKeyProvider{aead: aead}, nil
+}
+
+// newLocalKeyFile returns the contents of a local key file for a new random
+// master key, protected by passphrase unless it is empty.
+func newLocalKeyFile(passphrase string) ([]byte, error) {
+	masterKey, err := newDataKey()
+	if err != nil {
+		return nil, err
+	}
+	defer fpe.ZeroBytes(masterKey)
+	if passphrase == "" {
+		return []byte(hex.EncodeToString(masterKey) + "\n"), nil
+	}
+
+	salt := make([]byte, localKeySaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	protection, err := newLocalProtection(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := protection.seal(masterKey, []byte("fpe local master key"))
+	if err != nil {
+		return nil, err
+	}
+	return []byte(hex.EncodeToString(append(salt, sealed...)) + "\n"), nil
+}
+
+// newLocalProtection returns the localKeyProvider that protects a local key
+// file with the key derived from its passphrase and salt.
+func newLocalProtection(passphrase string, salt []byte) (*localKeyProvider, error) {
+	key := pbkdf2.Key([]byte(passphrase), salt, localKeyIterations, dataKeySize, sha256.New)
+	defer fpe.ZeroBytes(key)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &localKeyProvider{aead: aead}, nil
+}
+
+func (provider *localKeyProvider) Encrypt(plaintext []byte) ([]byte, error) {
+	return provider.seal(plaintext, []byte("fpe secret"))
+}
+
+func (provider *localKeyProvider) Decrypt(ciphertext []byte) ([]byte, error) {
+	return provider.open(ciphertext, []byte("fpe secret"))
+}
+
+func (provider *localKeyProvider) WrapKey(key []byte, label string) ([]byte, error) {
+	return provider.seal(key, []byte("fpe wrapped key "+label))
+}
+
+func (provider *localKeyProvider) UnwrapKey(wrapped []byte, label string) ([]byte, error) {
+	key, err := provider.open(wrapped, []byte("fpe wrapped key "+label))
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != dataKeySize {
+		return nil, errors.New("unwrapped data key is not 32 bytes")
+	}
+	return key, nil
+}
+
+// seal encrypts plaintext with a new random nonce, which it returns followed
+// by the sealed plaintext.
+func (provider *localKeyProvider) seal(plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, provider.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return provider.aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// open decrypts a ciphertext from seal with the same additional data.
+func (provider *localKeyProvider) open(ciphertext, additionalData []byte) ([]byte, error) {
+	nonceSize := provider.aead.NonceSize()
+	if len(ciphertext) < nonceSize {
+		return nil, errors.New("ciphertext is too short")
+	}
+	return provider.aead.Open(nil, ciphertext[:nonceSize], ciphertext[nonceSize:], additionalData)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeLocalKeyFile writes a new local key file protected by passphrase into
+// a temporary directory and returns its path.
+func writeLocalKeyFile(t *testing.T, passphrase string) string {
+	contents, err := newLocalKeyFile(passphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "fpe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "localkey")
+	if err := ioutil.WriteFile(path, contents, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLocalKeyProvider(t *testing.T) {
+	t.Log("Testing the local key provider encrypts secrets and wraps keys... ")
+	path := writeLocalKeyFile(t, "")
+	defer os.RemoveAll(filepath.Dir(path))
+	provider, err := newLocalKeyProvider(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secret := []byte("service key")
+	ciphertext, err := provider.Encrypt(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext, err := provider.Decrypt(ciphertext)
+	if err != nil || !bytes.Equal(secret, plaintext) {
+		t.Fatalf("Expected %q, got %q and %v", secret, plaintext, err)
+	}
+
+	key, err := newDataKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrapped, err := provider.WrapKey(key, "ssn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unwrapped, err := provider.UnwrapKey(wrapped, "ssn")
+	if err != nil || !bytes.Equal(key, unwrapped) {
+		t.Fatalf("Expected the data key back, got %x and %v", unwrapped, err)
+	}
+	if _, err := provider.UnwrapKey(wrapped, "phone"); err == nil {
+		t.Errorf("Expected an error unwrapping with another label")
+	}
+	if _, err := provider.Decrypt(wrapped); err == nil {
+		t.Errorf("Expected an error decrypting a wrapped key as a secret")
+	}
+
+	reloaded, err := newLocalKeyProvider(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext, err = reloaded.Decrypt(ciphertext)
+	if err != nil || !bytes.Equal(secret, plaintext) {
+		t.Fatalf("Expected %q from the reloaded key file, got %q and %v", secret, plaintext, err)
+	}
+}
+
+func TestLocalKeyProviderPassphrase(t *testing.T) {
+	t.Log("Testing the local key provider with a passphrase protected key file... ")
+	path := writeLocalKeyFile(t, "correct horse battery staple")
+	defer os.RemoveAll(filepath.Dir(path))
+	provider, err := newLocalKeyProvider(path, "correct horse battery staple")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err := provider.Encrypt([]byte("service key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.Decrypt(ciphertext); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newLocalKeyProvider(path, "wrong passphrase"); err == nil {
+		t.Errorf("Expected an error with the wrong passphrase")
+	}
+	if _, err := newLocalKeyProvider(path, ""); err == nil {
+		t.Errorf("Expected an error without the passphrase")
+	}
+}